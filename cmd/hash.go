@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var hashCmd = &cobra.Command{
+	Use:   "hash [password]",
+	Short: "Generate a bcrypt hash for auth.password or users[].token",
+	Long: `Generate a bcrypt hash that can be used as auth.password or users[].token in config.yaml.
+If password is omitted it is read from stdin (without echo on a terminal).
+Users with a hashed token authenticate with token=<name>.<token>.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var password string
+		if len(args) == 1 {
+			password = args[0]
+		} else {
+			var err error
+			password, err = readPassword()
+			if err != nil {
+				return err
+			}
+		}
+		if password == "" {
+			return fmt.Errorf("password cannot be empty")
+		}
+
+		hash, err := util.GeneratePasswordHash(password)
+		if err != nil {
+			return fmt.Errorf("generate hash error: %w", err)
+		}
+		fmt.Println(hash)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hashCmd)
+}
+
+// readPassword 从终端（不回显）或标准输入读取密码
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("read password error: %w", err)
+		}
+		return string(data), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password error: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
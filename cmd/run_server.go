@@ -10,6 +10,7 @@ import (
 	"github.com/haierkeys/singbox-subscribe-convert/global"
 	"github.com/haierkeys/singbox-subscribe-convert/internal/fetcher"
 	"github.com/haierkeys/singbox-subscribe-convert/internal/handler"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/snapshot"
 	"github.com/haierkeys/singbox-subscribe-convert/internal/watcher"
 	"github.com/haierkeys/singbox-subscribe-convert/pkg/fileurl"
 	"github.com/haierkeys/singbox-subscribe-convert/pkg/logger"
@@ -22,6 +23,7 @@ import (
 type Server struct {
 	logger     *zap.Logger           // 日志记录器
 	httpServer *http.Server          // HTTP 服务器实例
+	handler    *handler.Handler      // 请求处理器
 	sc         *safe_close.SafeClose // 安全关闭管理器
 	ctx        context.Context       // 上下文，用于控制后台任务
 	cancel     context.CancelFunc    // 取消函数，用于停止后台任务
@@ -69,7 +71,8 @@ func NewServer(runEnv *runFlags) (*Server, error) {
 		return nil, err
 	}
 
-	// 初始化文件获取器（fetcher）
+	// 初始化缓存快照与文件获取器（fetcher）
+	snapshot.Init(cfg)
 	fetcher.Init(cfg, s.logger)
 
 	// 初始化数据（首次获取远程文件或使用缓存）
@@ -79,10 +82,12 @@ func NewServer(runEnv *runFlags) (*Server, error) {
 	}
 
 	// 初始化请求处理器（handler）
-	if err := handler.Init(cfg, s.logger); err != nil {
+	h, err := handler.New(cfg, s.logger)
+	if err != nil {
 		s.logger.Error("Failed to initialize handler", zap.Error(err))
 		return nil, fmt.Errorf("handler init failed: %w", err)
 	}
+	s.handler = h
 
 	// 启动后台服务（自动更新、文件监控）
 	s.startBackgroundServices(cfg)
@@ -144,8 +149,13 @@ func (s *Server) startBackgroundServices(cfg *global.Config) {
 	)
 	go s.startAutoUpdate(cfg)
 
+	// 每个订阅源按各自的刷新间隔独立更新
+	for _, sub := range cfg.GetEnabledSubscriptions() {
+		go s.startSubscriptionAutoUpdate(sub)
+	}
+
 	// 启动配置文件监控服务（监控配置变化并自动重载）
-	go watcher.Start(s.ctx, cfg, s.logger, handler.ReloadData, handler.ReloadTemplateByName)
+	go watcher.Start(s.ctx, cfg, s.logger, s.handler.ReloadDataIfChanged, s.handler.ReloadTemplateIfChanged)
 }
 
 // logStartupInfo 记录服务器启动信息
@@ -167,7 +177,7 @@ func (s *Server) logStartupInfo(configPath string, cfg *global.Config) {
 	s.logger.Info("Configuration loaded",
 		zap.String("config_file", configPath),
 		zap.Int("server_port", cfg.Server.Port),
-		zap.String("subscription_url", cfg.Subscription.URL),
+		zap.Int("subscriptions", len(cfg.GetEnabledSubscriptions())),
 		zap.String("default_template", cfg.DefaultTemplate),
 		zap.Strings("enabled_templates", templateNames),
 		zap.String("cache_directory", cfg.Cache.Directory),
@@ -184,9 +194,13 @@ func (s *Server) startHTTPServer(cfg *global.Config) error {
 
 	// 注册路由
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", handler.HandleRequest)        // 主要订阅转换接口
-	mux.HandleFunc("/health", handler.HandleHealth)   // 健康检查接口
-	mux.HandleFunc("/refresh", handler.HandleRefresh) // 手动刷新接口
+	mux.HandleFunc("/", s.handler.HandleRequest)            // 主要订阅转换接口
+	mux.HandleFunc("/health", s.handler.HandleHealth)       // 健康检查接口
+	mux.HandleFunc("/refresh", s.handler.HandleRefresh)     // 手动刷新接口
+	mux.HandleFunc("/snapshots", s.handler.HandleSnapshots) // 缓存快照管理接口
+	mux.HandleFunc("/sign", s.handler.HandleSign)           // 签名链接生成接口
+	mux.HandleFunc("/filters", s.handler.HandleFilters)     // 节点过滤调试接口
+	mux.HandleFunc("/nodes", s.handler.HandleNodes)         // 节点元数据接口
 
 	// 创建 HTTP 服务器
 	s.httpServer = &http.Server{
@@ -289,22 +303,29 @@ func (s *Server) performInitialFetch() error {
 	cfg := global.Cfg
 	var tasks []fetchTask
 
-	// 添加节点文件获取任务
-	tasks = append(tasks, fetchTask{
-		name:     "node",
-		fetchFn:  fetcher.FetchNodeFile,
-		printMsg: "Fetching node file...",
-	})
+	// 添加订阅源节点文件获取任务
+	for _, sub := range cfg.GetEnabledSubscriptions() {
+		subscription := sub
+		tasks = append(tasks, fetchTask{
+			name: fmt.Sprintf("node_%s", subscription.Name),
+			fetchFn: func() error {
+				_, err := fetcher.FetchSubscription(subscription)
+				return err
+			},
+			printMsg: fmt.Sprintf("Fetching subscription '%s'...", subscription.Name),
+		})
+	}
 
 	// 获取所有启用的模板
 	enabledTemplates := cfg.GetEnabledTemplates()
 	for name, tpl := range enabledTemplates {
 		templateName := name
-		templateURL := tpl.URL
+		templateConfig := tpl
 		tasks = append(tasks, fetchTask{
 			name: fmt.Sprintf("template_%s", templateName),
 			fetchFn: func() error {
-				return fetcher.FetchTemplateFileByName(templateName, templateURL)
+				_, err := fetcher.FetchTemplate(templateName, templateConfig)
+				return err
 			},
 			printMsg: fmt.Sprintf("Fetching template '%s' (%s)...", tpl.Name, templateName),
 		})
@@ -417,6 +438,37 @@ func (s *Server) startAutoUpdate(cfg *global.Config) {
 	}
 }
 
+// startSubscriptionAutoUpdate 按订阅源自身的刷新间隔定期更新节点文件
+func (s *Server) startSubscriptionAutoUpdate(sub global.SubscriptionConfig) {
+	ticker := time.NewTicker(sub.GetRefreshInterval())
+	defer ticker.Stop()
+
+	s.logger.Info("✓ Subscription auto-update started",
+		zap.String("subscription", sub.Name),
+		zap.Duration("interval", sub.GetRefreshInterval()),
+	)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+
+		case <-ticker.C:
+			// 节点文件写入后由文件监控触发 ReloadData
+			if _, err := fetcher.FetchSubscription(sub); err != nil {
+				s.logger.Warn("Subscription auto-update failed",
+					zap.String("subscription", sub.Name),
+					zap.Error(err),
+				)
+			} else {
+				s.logger.Info("Subscription auto-update completed",
+					zap.String("subscription", sub.Name),
+				)
+			}
+		}
+	}
+}
+
 // performAutoUpdate 执行自动更新
 func (s *Server) performAutoUpdate(updateNum int, cfg *global.Config) {
 	s.logger.Info("Auto-update triggered", zap.Int("update_number", updateNum))
@@ -425,22 +477,16 @@ func (s *Server) performAutoUpdate(updateNum int, cfg *global.Config) {
 
 	var tasks []fetchTask
 
-	// 添加节点文件更新任务
-	tasks = append(tasks, fetchTask{
-		name:     "node",
-		fetchFn:  fetcher.FetchNodeFile,
-		printMsg: "Updating node file...",
-	})
-
-	// 更新所有启用的模板
+	// 更新所有启用的模板（订阅源由 startSubscriptionAutoUpdate 独立更新）
 	enabledTemplates := cfg.GetEnabledTemplates()
 	for name, tpl := range enabledTemplates {
 		templateName := name
-		templateURL := tpl.URL
+		templateConfig := tpl
 		tasks = append(tasks, fetchTask{
 			name: fmt.Sprintf("template_%s", templateName),
 			fetchFn: func() error {
-				return fetcher.FetchTemplateFileByName(templateName, templateURL)
+				_, err := fetcher.FetchTemplate(templateName, templateConfig)
+				return err
 			},
 			printMsg: fmt.Sprintf("Updating template '%s' (%s)...", tpl.Name, templateName),
 		})
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/signer"
+
+	"github.com/spf13/cobra"
+)
+
+type signFlags struct {
+	baseURL  string // 服务访问地址
+	template string // 绑定的模板
+	filter   string // 绑定的节点过滤
+	ttl      string // 有效期
+	setType  string // 附加的 type 参数
+	format   string // 附加的 format 参数
+}
+
+var signEnv = new(signFlags)
+
+func init() {
+	signCommand := &cobra.Command{
+		Use:   "sign --base-url https://sub.example.com [-t template] [-f filter] [--ttl 7d]",
+		Short: "Create a signed, expiring subscription link",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := initConfig(); err != nil {
+				return err
+			}
+			_, err := global.Load(runEnv.config)
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := global.Cfg
+			if cfg.Auth.SigningKey == "" {
+				return fmt.Errorf("auth.signing_key is not configured")
+			}
+
+			templateName := signEnv.template
+			if templateName == "" {
+				templateName = cfg.DefaultTemplate
+			}
+			if _, exists := cfg.GetTemplate(templateName); !exists {
+				return fmt.Errorf("template '%s' not found", templateName)
+			}
+
+			if err := nodeexpr.Validate(signEnv.filter); err != nil {
+				return err
+			}
+
+			expiresAt, err := signer.ParseExpiry(signEnv.ttl, time.Now())
+			if err != nil {
+				return err
+			}
+
+			extra := url.Values{}
+			if signEnv.setType != "" {
+				extra.Set("type", signEnv.setType)
+			}
+			if signEnv.format != "" {
+				extra.Set("format", signEnv.format)
+			}
+
+			link, err := signer.BuildURL(signEnv.baseURL, cfg.Auth.SigningKey, signer.Claims{
+				Template:  templateName,
+				Filter:    signEnv.filter,
+				ExpiresAt: expiresAt.Unix(),
+			}, extra)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(link)
+			fmt.Printf("expires at: %s\n", expiresAt.Format(time.RFC3339))
+			return nil
+		},
+	}
+
+	rootCmd.AddCommand(signCommand)
+
+	fs := signCommand.Flags()
+	fs.StringVarP(&runEnv.dir, "dir", "d", "", "working directory")
+	fs.StringVarP(&runEnv.config, "config", "c", "", "config file path")
+	fs.StringVar(&signEnv.baseURL, "base-url", "", "public base url of the server, e.g. https://sub.example.com")
+	fs.StringVarP(&signEnv.template, "template", "t", "", "template bound to the link (default: default_template)")
+	fs.StringVarP(&signEnv.filter, "filter", "f", "", "node filter bound to the link")
+	fs.StringVar(&signEnv.ttl, "ttl", "7d", "expiry: RFC3339 time, duration (72h) or days (7d)")
+	fs.StringVar(&signEnv.setType, "type", "", "type parameter appended to the link")
+	fs.StringVar(&signEnv.format, "format", "", "format parameter appended to the link")
+	_ = signCommand.MarkFlagRequired("base-url")
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/fetcher"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/snapshot"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	snapshotCommand := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage cache snapshots (list / diff / rollback)",
+		Long: `Manage the snapshot history of node and template cache files.
+
+kind is one of: node (subscription), template, clash (clash template).
+A running server reloads automatically after rollback via its file watcher.
+Rollback pins the cache file so automatic updates do not overwrite it;
+run "snapshot unpin" or a manual refresh to resume updates.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := initConfig(); err != nil {
+				return err
+			}
+			if _, err := global.Load(runEnv.config); err != nil {
+				return err
+			}
+			snapshot.Init(global.Cfg)
+			fetcher.Init(global.Cfg, zap.NewNop())
+			return nil
+		},
+	}
+
+	listCommand := &cobra.Command{
+		Use:   "list [kind name]",
+		Short: "List snapshots",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("accepts 0 or 2 arg(s), received %d", len(args))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets := snapshot.Targets()
+			if len(args) == 2 {
+				target, err := snapshot.Resolve(args[0], args[1])
+				if err != nil {
+					return err
+				}
+				targets = []snapshot.Target{target}
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "KIND\tNAME\tID\tTIME\tSIZE\tPINNED")
+			for _, target := range targets {
+				snapshots, err := snapshot.List(target.Path)
+				if err != nil {
+					return err
+				}
+				pin, pinned := fetcher.LoadPin(target.Path)
+				for _, s := range snapshots {
+					mark := ""
+					if pinned && pin.Snapshot == s.ID {
+						mark = "*"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", target.Kind, target.Name, s.ID, s.Time.Local().Format("2006-01-02 15:04:05"), s.Size, mark)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+
+	diffCommand := &cobra.Command{
+		Use:   "diff kind name from [to]",
+		Short: "Show diff between two snapshots (to defaults to current cache file)",
+		Args:  cobra.RangeArgs(3, 4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := snapshot.Resolve(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			to := snapshot.Current
+			if len(args) == 4 {
+				to = args[3]
+			}
+			diff, err := snapshot.Diff(target.Path, args[2], to)
+			if err != nil {
+				return err
+			}
+			fmt.Print(diff)
+			return nil
+		},
+	}
+
+	rollbackCommand := &cobra.Command{
+		Use:   "rollback kind name id",
+		Short: "Restore cache file from a snapshot",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := snapshot.Resolve(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if err := fetcher.RestoreSnapshot(target, args[2]); err != nil {
+				return err
+			}
+			fmt.Printf("✓ %s '%s' rolled back to snapshot %s and pinned\n", target.Kind, target.Name, args[2])
+			return nil
+		},
+	}
+
+	unpinCommand := &cobra.Command{
+		Use:   "unpin kind name",
+		Short: "Clear the pin set by rollback so automatic updates resume",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := snapshot.Resolve(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			unpinned, err := fetcher.Unpin(target)
+			if err != nil {
+				return err
+			}
+			if !unpinned {
+				fmt.Printf("%s '%s' is not pinned\n", target.Kind, target.Name)
+				return nil
+			}
+			fmt.Printf("✓ %s '%s' unpinned\n", target.Kind, target.Name)
+			return nil
+		},
+	}
+
+	snapshotCommand.AddCommand(listCommand, diffCommand, rollbackCommand, unpinCommand)
+	rootCmd.AddCommand(snapshotCommand)
+
+	fs := snapshotCommand.PersistentFlags()
+	fs.StringVarP(&runEnv.dir, "dir", "d", "", "working directory")
+	fs.StringVarP(&runEnv.config, "config", "c", "", "config file path")
+}
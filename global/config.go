@@ -2,12 +2,17 @@ package global
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	_ "github.com/gookit/goutil/dump"
 	"github.com/haierkeys/singbox-subscribe-convert/pkg/fileurl"
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,32 +20,370 @@ import (
 type Config struct {
 	Server          ServerConfig              `yaml:"server"`
 	Auth            AuthConfig                `yaml:"auth"`
+	Users           []UserConfig              `yaml:"users"`
 	Subscription    SubscriptionConfig        `yaml:"subscription"`
+	Subscriptions   []SubscriptionConfig      `yaml:"subscriptions"`
 	Templates       map[string]TemplateConfig `yaml:"templates"`
 	DefaultTemplate string                    `yaml:"default_template"`
 	Cache           CacheConfig               `yaml:"cache"`
 	Cloudflare      CloudflareConfig          `yaml:"cloudflare"`
+	Validation      ValidationConfig          `yaml:"validation"`
+	Security        SecurityConfig            `yaml:"security"`
+	Rename          []RenameRule              `yaml:"rename"`
+	NodeFilter      NodeFilter                `yaml:"node_filter"`
+	Dedupe          DedupeConfig              `yaml:"dedupe"`
+	Region          RegionConfig              `yaml:"region"`
 	Logging         LoggingConfig             `yaml:"logging"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port         int `yaml:"port"`
-	ReadTimeout  int `yaml:"read_timeout"`
-	WriteTimeout int `yaml:"write_timeout"`
-	IdleTimeout  int `yaml:"idle_timeout"`
+	Port         int  `yaml:"port"`
+	ReadTimeout  int  `yaml:"read_timeout"`
+	WriteTimeout int  `yaml:"write_timeout"`
+	IdleTimeout  int  `yaml:"idle_timeout"`
+	Compression  bool `yaml:"compression"` // 是否按 Accept-Encoding 对订阅响应进行 br / gzip 压缩
 }
 
 // AuthConfig 认证配置
 type AuthConfig struct {
-	Password string `yaml:"password"`
+	Password   string `yaml:"password"`    // 明文或 bcrypt 哈希（可用 hash 命令生成）
+	SigningKey string `yaml:"signing_key"` // 可选，签名链接的 HMAC 密钥，为空时不启用签名链接
+}
+
+// UserConfig 订阅用户配置，每个用户使用独立的 token 访问
+type UserConfig struct {
+	Name      string   `yaml:"name"`
+	Token     string   `yaml:"token"`      // 明文或 bcrypt 哈希
+	Templates []string `yaml:"templates"`  // 允许使用的模板，为空表示全部启用的模板
+	Filter    string   `yaml:"filter"`     // 可选，节点筛选表达式，仅输出匹配的节点
+	ExpiresAt string   `yaml:"expires_at"` // 可选，过期时间，格式为 RFC3339 或 2006-01-02
+	Enabled   bool     `yaml:"enabled"`
+}
+
+// GetExpiresAt 解析过期时间，未配置时返回零值
+func (u UserConfig) GetExpiresAt() (time.Time, error) {
+	if u.ExpiresAt == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, u.ExpiresAt); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", u.ExpiresAt, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expires_at: %s", u.ExpiresAt)
+	}
+	// 仅配置日期时当天仍然有效
+	return t.AddDate(0, 0, 1), nil
+}
+
+// IsExpired 是否已过期
+func (u UserConfig) IsExpired(now time.Time) bool {
+	expiresAt, err := u.GetExpiresAt()
+	if err != nil {
+		return true
+	}
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// CanUseTemplate 是否允许使用指定模板
+func (u UserConfig) CanUseTemplate(name string) bool {
+	if len(u.Templates) == 0 {
+		return true
+	}
+	for _, t := range u.Templates {
+		if t == name {
+			return true
+		}
+	}
+	return false
 }
 
 // SubscriptionConfig 订阅配置
+// subscription 段作为全局默认值（及旧版单订阅）使用，subscriptions 列表中的每一项为一个独立订阅源
 type SubscriptionConfig struct {
-	URL             string `yaml:"url"`
-	Timeout         int    `yaml:"timeout"`          // 秒
-	RefreshInterval int    `yaml:"refresh_interval"` // 分钟
+	Name            string        `yaml:"name"`
+	URL             string        `yaml:"url"`
+	Timeout         int           `yaml:"timeout"`          // 秒
+	RefreshInterval int           `yaml:"refresh_interval"` // 分钟
+	MinNodes        int           `yaml:"min_nodes"`        // 最少节点数，下载内容低于该值时不覆盖缓存
+	RefreshCooldown int           `yaml:"refresh_cooldown"` // 仅在顶层 subscription 中生效：同一订阅源 / 模板两次拉取的最小间隔（秒），默认 30，-1 表示关闭
+	CacheBuster     bool          `yaml:"cache_buster"`     // 拉取时追加 _t 随机参数并发送 no-cache 请求头绕过 CDN 缓存，默认关闭
+	Retry           RetryConfig   `yaml:"retry"`            // 失败重试策略，未设置的字段使用顶层 subscription.retry
+	CircuitBreaker  BreakerConfig `yaml:"circuit_breaker"`  // 熔断策略，未设置的字段使用顶层 subscription.circuit_breaker
+	Transport       `yaml:",inline"`
+	Request         `yaml:",inline"`
+	Enabled         bool `yaml:"enabled"`
+}
+
+// Request 拉取上游时附加的请求头、查询参数与认证信息
+// 取值支持 env:NAME 从环境变量读取、file:/path 从文件读取（去除首尾空白），避免在配置文件中保存明文密钥
+type Request struct {
+	UserAgent string            `yaml:"user_agent"` // 默认 Singbox-Subscribe-Convert/1.0
+	Headers   map[string]string `yaml:"headers"`
+	Query     map[string]string `yaml:"query"` // 追加到 URL 的查询参数
+	Auth      UpstreamAuth      `yaml:"auth"`
+}
+
+// UpstreamAuth 上游认证方式
+type UpstreamAuth struct {
+	Type     string `yaml:"type"` // basic 或 bearer
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+// 上游认证类型
+const (
+	UpstreamAuthBasic  = "basic"
+	UpstreamAuthBearer = "bearer"
+)
+
+// DefaultUserAgent 未设置 user_agent 时使用的 User-Agent
+const DefaultUserAgent = "Singbox-Subscribe-Convert/1.0"
+
+// GetUserAgent 获取 User-Agent
+func (r Request) GetUserAgent() string {
+	if r.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return r.UserAgent
+}
+
+// validate 校验认证设置
+func (r Request) validate() error {
+	switch r.Auth.Type {
+	case "":
+	case UpstreamAuthBasic:
+		if r.Auth.Username == "" {
+			return fmt.Errorf("auth.username is required for basic auth")
+		}
+	case UpstreamAuthBearer:
+		if r.Auth.Token == "" {
+			return fmt.Errorf("auth.token is required for bearer auth")
+		}
+	default:
+		return fmt.Errorf("invalid auth.type: %s", r.Auth.Type)
+	}
+	return nil
+}
+
+// withDefaults 使用 def 补全 User-Agent 与请求头，同名请求头以当前设置为准；查询参数与认证信息不继承
+func (r Request) withDefaults(def Request) Request {
+	if r.UserAgent == "" {
+		r.UserAgent = def.UserAgent
+	}
+	if len(def.Headers) > 0 {
+		headers := make(map[string]string, len(def.Headers)+len(r.Headers))
+		for k, v := range def.Headers {
+			headers[k] = v
+		}
+		for k, v := range r.Headers {
+			headers[k] = v
+		}
+		r.Headers = headers
+	}
+	return r
+}
+
+// ResolveSecret 解析配置值：env:NAME 读取环境变量，file:/path 读取文件内容，其余原样返回
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return val, nil
+	case strings.HasPrefix(value, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("read secret file error: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return value, nil
+}
+
+// Transport 拉取上游使用的代理与 TLS 设置，订阅源未设置的字段使用顶层 subscription 中的值（insecure_skip_verify 除外），模板拉取使用顶层设置
+type Transport struct {
+	Proxy              string `yaml:"proxy"`                // 代理地址，支持 http、https、socks5、socks5h，可带用户名密码；direct 表示不使用代理
+	CAFile             string `yaml:"ca_file"`              // 额外信任的 CA 证书（PEM）
+	CertFile           string `yaml:"cert_file"`            // 客户端证书（PEM），需与 key_file 同时设置
+	KeyFile            string `yaml:"key_file"`             // 客户端私钥（PEM）
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // 跳过证书校验，仅用于自签名等可信环境；不继承，需在每个订阅源单独开启
+}
+
+// ProxyDirect 订阅源显式不使用代理
+const ProxyDirect = "direct"
+
+// GetProxyURL 解析代理地址，未设置或为 direct 时返回 nil
+func (t Transport) GetProxyURL() (*url.URL, error) {
+	if t.Proxy == "" || t.Proxy == ProxyDirect {
+		return nil, nil
+	}
+	u, err := url.Parse(t.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy: missing host")
+	}
+	return u, nil
+}
+
+// validate 校验代理与证书设置
+func (t Transport) validate() error {
+	if _, err := t.GetProxyURL(); err != nil {
+		return err
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+// withDefaults 使用 def 补全未设置的字段；insecure_skip_verify 只能由订阅源自行开启，不从 def 继承
+func (t Transport) withDefaults(def Transport) Transport {
+	if t.Proxy == "" {
+		t.Proxy = def.Proxy
+	}
+	if t.CAFile == "" {
+		t.CAFile = def.CAFile
+	}
+	if t.CertFile == "" && t.KeyFile == "" {
+		t.CertFile = def.CertFile
+		t.KeyFile = def.KeyFile
+	}
+	return t
+}
+
+// RetryConfig 上游拉取失败时的重试策略，等待时间按 backoff * 2^(n-1) 指数增长并加入随机抖动
+type RetryConfig struct {
+	MaxAttempts int     `yaml:"max_attempts"` // 最多尝试次数（含首次），默认 3，1 表示不重试
+	Backoff     int     `yaml:"backoff"`      // 首次重试前的等待时间（毫秒），默认 500
+	MaxBackoff  int     `yaml:"max_backoff"`  // 单次等待时间上限（毫秒），默认 10000
+	Jitter      float64 `yaml:"jitter"`       // 随机抖动比例（0-1），默认 0.2，-1 表示不抖动
+	RetryOn     []int   `yaml:"retry_on"`     // 可重试的 HTTP 状态码，默认 408、425、429、500、502、503、504
+}
+
+// defaultRetryStatus 默认可重试的 HTTP 状态码
+var defaultRetryStatus = []int{408, 425, 429, 500, 502, 503, 504}
+
+// GetMaxAttempts 获取最多尝试次数
+func (r RetryConfig) GetMaxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return 3
+	}
+	return r.MaxAttempts
+}
+
+// GetBackoff 获取第 attempt 次重试前的基础等待时间（不含抖动）
+func (r RetryConfig) GetBackoff(attempt int) time.Duration {
+	base := time.Duration(r.Backoff) * time.Millisecond
+	if r.Backoff <= 0 {
+		base = 500 * time.Millisecond
+	}
+	maxBackoff := time.Duration(r.MaxBackoff) * time.Millisecond
+	if r.MaxBackoff <= 0 {
+		maxBackoff = 10 * time.Second
+	}
+
+	wait := base
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// GetJitter 获取随机抖动比例
+func (r RetryConfig) GetJitter() float64 {
+	if r.Jitter < 0 {
+		return 0
+	}
+	if r.Jitter == 0 {
+		return 0.2
+	}
+	return r.Jitter
+}
+
+// IsRetryableStatus 状态码是否可重试
+func (r RetryConfig) IsRetryableStatus(code int) bool {
+	codes := r.RetryOn
+	if len(codes) == 0 {
+		codes = defaultRetryStatus
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaults 使用 def 补全未设置的字段
+func (r RetryConfig) withDefaults(def RetryConfig) RetryConfig {
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = def.MaxAttempts
+	}
+	if r.Backoff == 0 {
+		r.Backoff = def.Backoff
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = def.MaxBackoff
+	}
+	if r.Jitter == 0 {
+		r.Jitter = def.Jitter
+	}
+	if len(r.RetryOn) == 0 {
+		r.RetryOn = def.RetryOn
+	}
+	return r
+}
+
+// BreakerConfig 熔断策略：连续失败达到阈值后暂停拉取该上游，冷却结束后允许一次试探请求
+type BreakerConfig struct {
+	Threshold int `yaml:"threshold"` // 连续失败次数阈值，默认 5，-1 表示关闭
+	Cooldown  int `yaml:"cooldown"`  // 熔断持续时间（秒），默认 300
+}
+
+// GetThreshold 获取熔断阈值，返回 0 表示不熔断
+func (b BreakerConfig) GetThreshold() int {
+	if b.Threshold < 0 {
+		return 0
+	}
+	if b.Threshold == 0 {
+		return 5
+	}
+	return b.Threshold
+}
+
+// GetCooldown 获取熔断持续时间
+func (b BreakerConfig) GetCooldown() time.Duration {
+	if b.Cooldown <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(b.Cooldown) * time.Second
+}
+
+// withDefaults 使用 def 补全未设置的字段
+func (b BreakerConfig) withDefaults(def BreakerConfig) BreakerConfig {
+	if b.Threshold == 0 {
+		b.Threshold = def.Threshold
+	}
+	if b.Cooldown == 0 {
+		b.Cooldown = def.Cooldown
+	}
+	return b
 }
 
 // CloudflareConfig Cloudflare 配置
@@ -54,10 +397,178 @@ type CloudflareConfig struct {
 
 // TemplateConfig 模板配置
 type TemplateConfig struct {
-	URL     string `yaml:"url"`
-	Name    string `yaml:"name"`
-	NoNode  string `yaml:"no_node"`
-	Enabled bool   `yaml:"enabled"`
+	URL         string `yaml:"url"`
+	ClashURL    string `yaml:"clash_url"` // 可选，format=clash 时使用的 Clash.Meta YAML 模板
+	Type        string `yaml:"type"`      // 模板类型：text（默认，pongo2 文本模板）或 json（结构化 JSON 模板）
+	Version     string `yaml:"version"`   // 可选，模板目标 sing-box 版本（如 1.12），用于校验版本相关字段
+	Name        string `yaml:"name"`
+	NoNode      string `yaml:"no_node"`
+	CacheBuster bool   `yaml:"cache_buster"` // 拉取时追加 _t 随机参数并发送 no-cache 请求头绕过 CDN 缓存，默认关闭
+	Request     `yaml:",inline"`
+	NodeFilter  NodeFilter `yaml:"node_filter"` // 模板单独的节点过滤规则，在全局 node_filter 之后生效
+	Enabled     bool       `yaml:"enabled"`
+}
+
+// 模板类型
+const (
+	TemplateTypeText = "text"
+	TemplateTypeJSON = "json"
+)
+
+// IsStructured 是否为结构化 JSON 模板
+func (t TemplateConfig) IsStructured() bool {
+	return t.Type == TemplateTypeJSON
+}
+
+// DedupeConfig 节点去重配置
+type DedupeConfig struct {
+	Strategy string `yaml:"strategy"` // tag（默认）/ endpoint / credential
+}
+
+// 去重策略
+const (
+	DedupeTag        = "tag"        // 只处理同名节点，后出现的节点追加 " #2" 等后缀
+	DedupeEndpoint   = "endpoint"   // 协议、服务器与端口相同的节点只保留第一个
+	DedupeCredential = "credential" // 除 tag 外配置完全相同的节点只保留第一个
+)
+
+// GetStrategy 获取去重策略
+func (d DedupeConfig) GetStrategy() string {
+	if d.Strategy == "" {
+		return DedupeTag
+	}
+	return d.Strategy
+}
+
+// RenameRule 节点重命名规则，按配置顺序依次作用于所有节点
+type RenameRule struct {
+	Type    string   `yaml:"type"`    // replace / prefix / suffix / trim / template / flag
+	Pattern string   `yaml:"pattern"` // replace 为要替换的正则；其余类型可选，只处理名称匹配该正则的节点
+	Replace string   `yaml:"replace"` // replace 的替换内容，支持 $1 等分组引用
+	Value   string   `yaml:"value"`   // prefix / suffix 追加的文本，template 的名称格式，trim 额外去除的首尾字符
+	Sources []string `yaml:"sources"` // 只处理这些订阅源的节点，留空表示全部
+}
+
+// 重命名规则类型
+const (
+	RenameReplace  = "replace"
+	RenamePrefix   = "prefix"
+	RenameSuffix   = "suffix"
+	RenameTrim     = "trim"
+	RenameTemplate = "template"
+	RenameFlag     = "flag" // 在名称前添加识别出的地区旗帜，已有旗帜的名称不变
+)
+
+// RegionConfig 节点地区识别配置
+type RegionConfig struct {
+	GeoIPDatabase string `yaml:"geoip_database"` // 可选，GeoLite2 / GeoIP2 Country 或 City 数据库（mmdb）路径，名称无法识别地区时按服务器 IP 查询
+	Flag          bool   `yaml:"flag"`           // 在所有重命名规则之后为节点名称添加地区旗帜
+}
+
+// NodeFilter 节点过滤规则：设置了 include 时只保留匹配任一规则的节点，再移除匹配任一 exclude 规则的节点
+type NodeFilter struct {
+	Include []FilterRule `yaml:"include"`
+	Exclude []FilterRule `yaml:"exclude"`
+}
+
+// FilterRule 单条过滤规则，设置的条件需同时满足
+type FilterRule struct {
+	Pattern  string   `yaml:"pattern"`  // 名称正则
+	Keywords []string `yaml:"keywords"` // 名称包含任一关键词
+	Types    []string `yaml:"types"`    // 出站类型，如 shadowsocks、vmess
+	CIDRs    []string `yaml:"cidrs"`    // 服务器 IP 所在网段，server 为域名时不匹配
+	Ports    string   `yaml:"ports"`    // 服务器端口，如 "443,8443,10000-20000"
+	Expr     string   `yaml:"expr"`     // 节点筛选表达式，如 "香港 & type=hysteria2"
+}
+
+// IsEmpty 是否未设置任何规则
+func (f NodeFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// validate 校验过滤规则
+func (f NodeFilter) validate() error {
+	for _, group := range []struct {
+		kind  string
+		rules []FilterRule
+	}{{"include", f.Include}, {"exclude", f.Exclude}} {
+		kind := group.kind
+		for i, rule := range group.rules {
+			if rule.Pattern == "" && len(rule.Keywords) == 0 && len(rule.Types) == 0 && len(rule.CIDRs) == 0 && rule.Ports == "" && rule.Expr == "" {
+				return fmt.Errorf("%s[%d] has no conditions", kind, i)
+			}
+			if rule.Pattern != "" {
+				if _, err := regexp.Compile(rule.Pattern); err != nil {
+					return fmt.Errorf("%s[%d] invalid pattern: %w", kind, i, err)
+				}
+			}
+			for _, cidr := range rule.CIDRs {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return fmt.Errorf("%s[%d] invalid cidr: %s", kind, i, cidr)
+				}
+			}
+			if _, err := ParsePortRanges(rule.Ports); err != nil {
+				return fmt.Errorf("%s[%d] %w", kind, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// PortRange 端口范围，包含两端
+type PortRange struct {
+	From int
+	To   int
+}
+
+// ParsePortRanges 解析逗号分隔的端口与端口范围，如 "443,8443,10000-20000"
+func ParsePortRanges(value string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		var r PortRange
+		if _, err := fmt.Sscanf(strings.TrimSpace(from), "%d", &r.From); err != nil {
+			return nil, fmt.Errorf("invalid port: %s", part)
+		}
+		r.To = r.From
+		if isRange {
+			if _, err := fmt.Sscanf(strings.TrimSpace(to), "%d", &r.To); err != nil {
+				return nil, fmt.Errorf("invalid port range: %s", part)
+			}
+		}
+		if r.From < 1 || r.To > 65535 || r.From > r.To {
+			return nil, fmt.Errorf("invalid port range: %s", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// ValidationConfig 渲染结果校验配置
+type ValidationConfig struct {
+	Enabled   *bool  `yaml:"enabled"`    // 是否校验渲染结果，默认开启
+	OnFailure string `yaml:"on_failure"` // 校验失败时的处理：error（默认，返回结构化错误）或 last_good（返回上次通过校验的结果）
+	Strict    bool   `yaml:"strict"`     // 未知字段按错误处理，默认只记录警告
+}
+
+// 校验失败处理方式
+const (
+	OnFailureError    = "error"
+	OnFailureLastGood = "last_good"
+)
+
+// IsEnabled 是否启用渲染结果校验，未配置时默认开启
+func (v ValidationConfig) IsEnabled() bool {
+	return v.Enabled == nil || *v.Enabled
+}
+
+// ServeLastGood 校验失败时是否返回上次通过校验的结果
+func (v ValidationConfig) ServeLastGood() bool {
+	return v.OnFailure == OnFailureLastGood
 }
 
 // CacheConfig 缓存配置
@@ -65,6 +576,17 @@ type CacheConfig struct {
 	Directory    string `yaml:"directory"`
 	NodeFile     string `yaml:"node_file"`
 	TemplateFile string `yaml:"template_file"`
+	Snapshots    int    `yaml:"snapshots"` // 每个缓存文件保留的历史快照数量，默认 10，-1 表示关闭
+}
+
+// SecurityConfig 访问防护配置：鉴权失败锁定与请求频率限制
+type SecurityConfig struct {
+	TrustedProxies []string `yaml:"trusted_proxies"` // 可信代理的 IP 或 CIDR，来自这些地址的请求使用 CF-Connecting-IP / X-Forwarded-For 识别客户端
+	MaxFailures    int      `yaml:"max_failures"`    // 失败窗口内允许的鉴权失败次数，超过后锁定客户端 IP，默认 5，-1 表示关闭
+	FailureWindow  int      `yaml:"failure_window"`  // 失败计数窗口（秒），默认 600
+	Lockout        int      `yaml:"lockout"`         // 锁定时长（秒），默认 900
+	ClientRate     int      `yaml:"client_rate"`     // 每个客户端 IP 每分钟最多请求数，0 表示不限制
+	TokenRate      int      `yaml:"token_rate"`      // 每个 token / 签名链接每分钟最多请求数，0 表示不限制
 }
 
 type LoggingConfig struct {
@@ -82,6 +604,9 @@ type LoggingConfig struct {
 	MaxAge     int  `yaml:"max_age"`
 }
 
+// versionPattern sing-box 版本号格式，如 1.12 或 1.12.0
+var versionPattern = regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`)
+
 var (
 	// Cfg 全局配置实例
 	Cfg *Config
@@ -112,6 +637,10 @@ func Load(configPath string) (string, error) {
 	// 环境变量覆盖
 	cfg.overrideWithEnv()
 
+	// 兼容旧版单订阅配置
+	cfg.normalizeSubscriptions()
+	cfg.normalizeTemplates()
+
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
 		return "", fmt.Errorf("validate config error: %w", err)
@@ -130,8 +659,22 @@ func (c *Config) overrideWithEnv() {
 	if val := os.Getenv("PASSWORD"); val != "" {
 		c.Auth.Password = val
 	}
+	if val := os.Getenv("SIGNING_KEY"); val != "" {
+		c.Auth.SigningKey = val
+	}
 	if val := os.Getenv("SUBSCRIPTION_URL"); val != "" {
+		// 环境变量替换订阅源列表，保留配置文件中 default 订阅源的其他设置
 		c.Subscription.URL = val
+		sub := SubscriptionConfig{Name: "default", CacheBuster: c.Subscription.CacheBuster, Request: c.Subscription.Request}
+		for _, existing := range c.Subscriptions {
+			if existing.Name == "default" {
+				sub = existing
+				break
+			}
+		}
+		sub.URL = val
+		sub.Enabled = true
+		c.Subscriptions = []SubscriptionConfig{sub}
 	}
 	if val := os.Getenv("DEFAULT_TEMPLATE"); val != "" {
 		c.DefaultTemplate = val
@@ -144,6 +687,44 @@ func (c *Config) overrideWithEnv() {
 	}
 }
 
+// normalizeSubscriptions 将旧版 subscription.url 转换为订阅源列表，并补全各订阅源的默认值
+func (c *Config) normalizeSubscriptions() {
+	if len(c.Subscriptions) == 0 && c.Subscription.URL != "" {
+		c.Subscriptions = append(c.Subscriptions, SubscriptionConfig{
+			Name:        "default",
+			URL:         c.Subscription.URL,
+			CacheBuster: c.Subscription.CacheBuster,
+			Request:     c.Subscription.Request,
+			Enabled:     true,
+		})
+	}
+
+	for i := range c.Subscriptions {
+		sub := &c.Subscriptions[i]
+		if sub.Timeout <= 0 {
+			sub.Timeout = c.Subscription.Timeout
+		}
+		if sub.RefreshInterval <= 0 {
+			sub.RefreshInterval = c.Subscription.RefreshInterval
+		}
+		if sub.MinNodes <= 0 {
+			sub.MinNodes = c.Subscription.MinNodes
+		}
+		sub.Retry = sub.Retry.withDefaults(c.Subscription.Retry)
+		sub.CircuitBreaker = sub.CircuitBreaker.withDefaults(c.Subscription.CircuitBreaker)
+		sub.Transport = sub.Transport.withDefaults(c.Subscription.Transport)
+		sub.Request = sub.Request.withDefaults(c.Subscription.Request)
+	}
+}
+
+// normalizeTemplates 补全各模板的请求设置
+func (c *Config) normalizeTemplates() {
+	for name, tpl := range c.Templates {
+		tpl.Request = tpl.Request.withDefaults(c.Subscription.Request)
+		c.Templates[name] = tpl
+	}
+}
+
 // Validate 验证配置
 func (c *Config) Validate() error {
 	if c.Server.Port <= 0 || c.Server.Port > 65535 {
@@ -155,11 +736,31 @@ func (c *Config) Validate() error {
 	if c.Cache.Directory == "" {
 		return fmt.Errorf("cache directory cannot be empty")
 	}
-	if c.Subscription.URL == "" {
-		return fmt.Errorf("subscription url cannot be empty")
+	if len(c.Subscriptions) == 0 {
+		return fmt.Errorf("at least one subscription must be configured")
 	}
-	if c.Subscription.RefreshInterval <= 0 {
-		return fmt.Errorf("subscription refresh_interval must be greater than 0")
+	subNames := make(map[string]bool)
+	for i, sub := range c.Subscriptions {
+		if sub.Name == "" {
+			return fmt.Errorf("subscriptions[%d] name cannot be empty", i)
+		}
+		if strings.ContainsAny(sub.Name, `/\:*?"<>| `) {
+			return fmt.Errorf("subscription '%s' name contains invalid characters", sub.Name)
+		}
+		if subNames[sub.Name] {
+			return fmt.Errorf("duplicate subscription name: %s", sub.Name)
+		}
+		subNames[sub.Name] = true
+		if sub.URL == "" {
+			return fmt.Errorf("subscription '%s' url cannot be empty", sub.Name)
+		}
+		// 订阅源未设置时已回退到顶层 subscription.refresh_interval，此处同时覆盖两者
+		if sub.RefreshInterval <= 0 {
+			return fmt.Errorf("subscription '%s' refresh_interval must be greater than 0 (set it on the subscription or in subscription.refresh_interval)", sub.Name)
+		}
+	}
+	if len(c.GetEnabledSubscriptions()) == 0 {
+		return fmt.Errorf("at least one subscription must be enabled")
 	}
 	if len(c.Templates) == 0 {
 		return fmt.Errorf("at least one template must be configured")
@@ -171,6 +772,121 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("default_template '%s' not found in templates", c.DefaultTemplate)
 	}
 
+	for name, tpl := range c.Templates {
+		if tpl.Type != "" && tpl.Type != TemplateTypeText && tpl.Type != TemplateTypeJSON {
+			return fmt.Errorf("template '%s' has invalid type: %s", name, tpl.Type)
+		}
+		if tpl.Version != "" && !versionPattern.MatchString(tpl.Version) {
+			return fmt.Errorf("template '%s' has invalid version: %s", name, tpl.Version)
+		}
+		if err := tpl.Request.validate(); err != nil {
+			return fmt.Errorf("template '%s' %w", name, err)
+		}
+		if err := tpl.NodeFilter.validate(); err != nil {
+			return fmt.Errorf("template '%s' node_filter %w", name, err)
+		}
+	}
+
+	if c.Auth.SigningKey != "" && len(c.Auth.SigningKey) < 16 {
+		return fmt.Errorf("auth.signing_key must be at least 16 characters")
+	}
+
+	userNames := make(map[string]bool)
+	userTokens := make(map[string]bool)
+	for i, user := range c.Users {
+		if user.Name == "" {
+			return fmt.Errorf("users[%d] name cannot be empty", i)
+		}
+		if userNames[user.Name] {
+			return fmt.Errorf("duplicate user name: %s", user.Name)
+		}
+		userNames[user.Name] = true
+		if user.Token == "" {
+			return fmt.Errorf("user '%s' token cannot be empty", user.Name)
+		}
+		if user.Token == c.Auth.Password || userTokens[user.Token] {
+			return fmt.Errorf("user '%s' token must be unique", user.Name)
+		}
+		if util.IsPasswordHash(user.Token) && strings.Contains(user.Name, ".") {
+			return fmt.Errorf("user '%s' name cannot contain '.' when token is a bcrypt hash", user.Name)
+		}
+		userTokens[user.Token] = true
+		for _, name := range user.Templates {
+			if _, exists := c.Templates[name]; !exists {
+				return fmt.Errorf("user '%s' template '%s' not found in templates", user.Name, name)
+			}
+		}
+		if _, err := user.GetExpiresAt(); err != nil {
+			return fmt.Errorf("user '%s' %w", user.Name, err)
+		}
+	}
+
+	if _, err := c.GetTrustedProxies(); err != nil {
+		return err
+	}
+	if c.Security.ClientRate < 0 || c.Security.TokenRate < 0 {
+		return fmt.Errorf("security client_rate and token_rate cannot be negative")
+	}
+
+	if c.Subscription.Retry.Jitter > 1 {
+		return fmt.Errorf("subscription retry.jitter must be between 0 and 1")
+	}
+	for _, sub := range c.Subscriptions {
+		if sub.Retry.Jitter > 1 {
+			return fmt.Errorf("subscription '%s' retry.jitter must be between 0 and 1", sub.Name)
+		}
+	}
+	if err := c.Subscription.Transport.validate(); err != nil {
+		return fmt.Errorf("subscription %w", err)
+	}
+	if err := c.Subscription.Request.validate(); err != nil {
+		return fmt.Errorf("subscription %w", err)
+	}
+	for _, sub := range c.Subscriptions {
+		if err := sub.Transport.validate(); err != nil {
+			return fmt.Errorf("subscription '%s' %w", sub.Name, err)
+		}
+		if err := sub.Request.validate(); err != nil {
+			return fmt.Errorf("subscription '%s' %w", sub.Name, err)
+		}
+	}
+
+	for i, rule := range c.Rename {
+		switch rule.Type {
+		case RenameReplace:
+			if rule.Pattern == "" {
+				return fmt.Errorf("rename[%d] pattern is required for replace", i)
+			}
+		case RenamePrefix, RenameSuffix, RenameTemplate:
+			if rule.Value == "" {
+				return fmt.Errorf("rename[%d] value is required for %s", i, rule.Type)
+			}
+		case RenameTrim, RenameFlag:
+		default:
+			return fmt.Errorf("rename[%d] invalid type: %s", i, rule.Type)
+		}
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("rename[%d] invalid pattern: %w", i, err)
+			}
+		}
+	}
+
+	if err := c.NodeFilter.validate(); err != nil {
+		return fmt.Errorf("node_filter %w", err)
+	}
+	switch c.Dedupe.GetStrategy() {
+	case DedupeTag, DedupeEndpoint, DedupeCredential:
+	default:
+		return fmt.Errorf("invalid dedupe.strategy: %s", c.Dedupe.Strategy)
+	}
+
+	switch c.Validation.OnFailure {
+	case "", OnFailureError, OnFailureLastGood:
+	default:
+		return fmt.Errorf("invalid validation.on_failure: %s", c.Validation.OnFailure)
+	}
+
 	// 验证至少有一个启用的模板
 	hasEnabled := false
 	for _, tpl := range c.Templates {
@@ -186,9 +902,65 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-// GetNodeFilePath 获取节点文件缓存路径
-func (c *Config) GetNodeFilePath() string {
-	return filepath.Join(c.Cache.Directory, c.Cache.NodeFile)
+// GetSubscriptionFilePathByName 根据订阅源名称获取节点文件缓存路径
+func (c *Config) GetSubscriptionFilePathByName(name string) string {
+	return filepath.Join(c.Cache.Directory, fmt.Sprintf("node_%s.json", name))
+}
+
+// GetEnabledSubscriptions 获取所有启用的订阅源（保持配置顺序）
+func (c *Config) GetEnabledSubscriptions() []SubscriptionConfig {
+	enabled := make([]SubscriptionConfig, 0, len(c.Subscriptions))
+	for _, sub := range c.Subscriptions {
+		if sub.Enabled {
+			enabled = append(enabled, sub)
+		}
+	}
+	return enabled
+}
+
+// GetSubscription 根据名称获取订阅源配置
+func (c *Config) GetSubscription(name string) (SubscriptionConfig, bool) {
+	for _, sub := range c.Subscriptions {
+		if sub.Name == name {
+			return sub, true
+		}
+	}
+	return SubscriptionConfig{}, false
+}
+
+// GetUserByToken 根据 token 查找启用的用户，每次最多进行一次 bcrypt 计算：
+// 明文 token 直接比较；配置为 bcrypt 哈希的 token 需以 "用户名.token" 形式传入，按用户名定位后再校验
+func (c *Config) GetUserByToken(token string) (UserConfig, bool) {
+	for _, user := range c.Users {
+		if user.Enabled && !util.IsPasswordHash(user.Token) && util.VerifyPassword(user.Token, token) {
+			return user, true
+		}
+	}
+
+	name, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return UserConfig{}, false
+	}
+	user, ok := c.GetUser(name)
+	if !ok || !util.IsPasswordHash(user.Token) || !util.VerifyPassword(user.Token, secret) {
+		return UserConfig{}, false
+	}
+	return user, true
+}
+
+// GetUser 根据名称查找启用的用户
+func (c *Config) GetUser(name string) (UserConfig, bool) {
+	for _, user := range c.Users {
+		if user.Enabled && user.Name == name {
+			return user, true
+		}
+	}
+	return UserConfig{}, false
+}
+
+// CheckAdminPassword 校验管理员密码，password 可配置为明文或 bcrypt 哈希
+func (c *Config) CheckAdminPassword(password string) bool {
+	return util.VerifyPassword(c.Auth.Password, password)
 }
 
 // GetTemplateFilePathByName 根据模板名称获取模板文件缓存路径
@@ -196,6 +968,79 @@ func (c *Config) GetTemplateFilePathByName(templateName string) string {
 	return filepath.Join(c.Cache.Directory, fmt.Sprintf("template_%s.json", templateName))
 }
 
+// GetClashTemplateFilePathByName 根据模板名称获取 Clash 模板文件缓存路径
+func (c *Config) GetClashTemplateFilePathByName(templateName string) string {
+	return filepath.Join(c.Cache.Directory, fmt.Sprintf("template_%s_clash.yaml", templateName))
+}
+
+// GetSnapshotLimit 获取每个缓存文件保留的快照数量，返回 0 表示不保留快照
+func (c *Config) GetSnapshotLimit() int {
+	if c.Cache.Snapshots < 0 {
+		return 0
+	}
+	if c.Cache.Snapshots == 0 {
+		return 10
+	}
+	return c.Cache.Snapshots
+}
+
+// GetSnapshotDirectory 获取快照目录
+func (c *Config) GetSnapshotDirectory() string {
+	return filepath.Join(c.Cache.Directory, "snapshots")
+}
+
+// GetTrustedProxies 解析可信代理地址，单个 IP 视为 /32 或 /128
+func (c *Config) GetTrustedProxies() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(c.Security.TrustedProxies))
+	for _, item := range c.Security.TrustedProxies {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid security.trusted_proxies entry: %s", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid security.trusted_proxies entry: %s", item)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// GetMaxAuthFailures 获取锁定前允许的鉴权失败次数，返回 0 表示不锁定
+func (c *Config) GetMaxAuthFailures() int {
+	if c.Security.MaxFailures < 0 {
+		return 0
+	}
+	if c.Security.MaxFailures == 0 {
+		return 5
+	}
+	return c.Security.MaxFailures
+}
+
+// GetFailureWindow 获取鉴权失败计数窗口
+func (c *Config) GetFailureWindow() time.Duration {
+	if c.Security.FailureWindow <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.Security.FailureWindow) * time.Second
+}
+
+// GetLockoutDuration 获取锁定时长
+func (c *Config) GetLockoutDuration() time.Duration {
+	if c.Security.Lockout <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.Security.Lockout) * time.Second
+}
+
 // GetEnabledTemplates 获取所有启用的模板
 func (c *Config) GetEnabledTemplates() map[string]TemplateConfig {
 	enabled := make(map[string]TemplateConfig)
@@ -226,9 +1071,28 @@ func (c *Config) GetLogFilePath() string {
 	return c.Logging.File
 }
 
-// GetRefreshInterval 获取刷新间隔
+// GetRefreshInterval 获取模板的刷新间隔，顶层 subscription.refresh_interval 未设置时使用订阅源中最短的刷新间隔
 func (c *Config) GetRefreshInterval() time.Duration {
-	return time.Duration(c.Subscription.RefreshInterval) * time.Minute
+	interval := c.Subscription.RefreshInterval
+	if interval <= 0 {
+		for _, sub := range c.GetEnabledSubscriptions() {
+			if interval <= 0 || sub.RefreshInterval < interval {
+				interval = sub.RefreshInterval
+			}
+		}
+	}
+	return time.Duration(interval) * time.Minute
+}
+
+// GetRefreshCooldown 获取同一目标两次拉取的最小间隔，返回 0 表示不限制
+func (c *Config) GetRefreshCooldown() time.Duration {
+	if c.Subscription.RefreshCooldown < 0 {
+		return 0
+	}
+	if c.Subscription.RefreshCooldown == 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Subscription.RefreshCooldown) * time.Second
 }
 
 // GetRequestTimeout 获取请求超时
@@ -239,6 +1103,27 @@ func (c *Config) GetRequestTimeout() time.Duration {
 	return 30 * time.Second
 }
 
+// GetTimeout 获取订阅源请求超时
+func (s SubscriptionConfig) GetTimeout() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// GetMinNodes 获取订阅源最少节点数，默认为 1
+func (s SubscriptionConfig) GetMinNodes() int {
+	if s.MinNodes > 0 {
+		return s.MinNodes
+	}
+	return 1
+}
+
+// GetRefreshInterval 获取订阅源刷新间隔
+func (s SubscriptionConfig) GetRefreshInterval() time.Duration {
+	return time.Duration(s.RefreshInterval) * time.Minute
+}
+
 // GetServerReadTimeout 获取服务器读取超时
 func (c *Config) GetServerReadTimeout() time.Duration {
 	return time.Duration(c.Server.ReadTimeout) * time.Second
@@ -0,0 +1,49 @@
+package global
+
+import (
+	"testing"
+
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+)
+
+func TestGetUserByToken(t *testing.T) {
+	hash := func(token string) string {
+		h, err := util.GeneratePasswordHash(token)
+		if err != nil {
+			t.Fatalf("hash error: %v", err)
+		}
+		return h
+	}
+	c := &Config{Users: []UserConfig{
+		{Name: "alice", Token: "tok-alice", Enabled: true},
+		{Name: "carol", Token: "tok-carol"},
+		{Name: "dave", Token: hash("tok-dave"), Enabled: true},
+		{Name: "erin", Token: hash("tok-erin")},
+	}}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string // 匹配的用户名，为空表示不匹配
+	}{
+		{"plaintext", "tok-alice", "alice"},
+		{"plaintext disabled", "tok-carol", ""},
+		{"plaintext in name.token form", "alice.tok-alice", ""},
+		{"plaintext wrong token", "tok-bob", ""},
+		{"bcrypt name.token", "dave.tok-dave", "dave"},
+		{"bcrypt without name", "tok-dave", ""},
+		{"bcrypt wrong token", "dave.tok-alice", ""},
+		{"bcrypt wrong name", "alice.tok-dave", ""},
+		{"bcrypt hash as token", "dave." + c.Users[2].Token, ""},
+		{"bcrypt disabled", "erin.tok-erin", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, ok := c.GetUserByToken(tt.token)
+			if ok != (tt.want != "") || user.Name != tt.want {
+				t.Errorf("GetUserByToken(%q) = %q, %t, want %q", tt.token, user.Name, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clashConverter 单个 sing-box 出站类型到 Clash 节点的转换函数
+type clashConverter func(o outbound, proxy map[string]interface{}) error
+
+// clashConverters sing-box outbound type -> 转换函数
+var clashConverters = map[string]clashConverter{
+	"shadowsocks": shadowsocksToClash,
+	"vmess":       vmessToClash,
+	"vless":       vlessToClash,
+	"trojan":      trojanToClash,
+	"hysteria2":   hysteria2ToClash,
+	"tuic":        tuicToClash,
+	"wireguard":   wireguardToClash,
+	"socks":       socksToClash,
+	"http":        httpToClash,
+}
+
+// ToClashProxy 将单个 sing-box outbound 转换为 Clash.Meta 节点
+func ToClashProxy(node map[string]interface{}) (map[string]interface{}, error) {
+	o := outbound(node)
+	convert, ok := clashConverters[o.str("type")]
+	if !ok {
+		return nil, fmt.Errorf("unsupported type: %s", o.str("type"))
+	}
+
+	proxy := map[string]interface{}{
+		"name":   o.str("tag"),
+		"server": o.str("server"),
+		"port":   o.int("server_port"),
+	}
+	if o.bool("tcp_fast_open") {
+		proxy["tfo"] = true
+	}
+	if o.bool("tcp_multi_path") {
+		proxy["mptcp"] = true
+	}
+
+	if err := convert(o, proxy); err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// ToClashProxies 批量转换节点，无法转换的节点跳过并以 warnings 返回
+func ToClashProxies(nodes []map[string]interface{}) ([]map[string]interface{}, []string) {
+	proxies := make([]map[string]interface{}, 0, len(nodes))
+	var warnings []string
+
+	for _, node := range nodes {
+		proxy, err := ToClashProxy(node)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", outbound(node).str("tag"), err))
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, warnings
+}
+
+// ClashProxiesFlow 将节点列表输出为 YAML flow 序列（JSON 数组语法），用于插入模板中的 proxies 字段
+func ClashProxiesFlow(proxies []map[string]interface{}) string {
+	items := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		b, _ := json.Marshal(proxy)
+		items = append(items, string(b))
+	}
+	return "[" + strings.Join(items, ",\n  ") + "]"
+}
+
+// clashConfig 未配置 Clash 模板时使用的内置配置
+type clashConfig struct {
+	MixedPort   int                      `yaml:"mixed-port"`
+	AllowLan    bool                     `yaml:"allow-lan"`
+	Mode        string                   `yaml:"mode"`
+	LogLevel    string                   `yaml:"log-level"`
+	Proxies     []map[string]interface{} `yaml:"proxies"`
+	ProxyGroups []clashProxyGroup        `yaml:"proxy-groups"`
+	Rules       []string                 `yaml:"rules"`
+}
+
+// clashProxyGroup Clash 策略组
+type clashProxyGroup struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"`
+	Proxies  []string `yaml:"proxies"`
+	URL      string   `yaml:"url,omitempty"`
+	Interval int      `yaml:"interval,omitempty"`
+}
+
+// DefaultClashConfig 生成包含全部节点、手动选择与自动测速策略组的内置 Clash.Meta 配置
+func DefaultClashConfig(proxies []map[string]interface{}) ([]byte, error) {
+	names := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		names = append(names, outbound(proxy).str("name"))
+	}
+
+	selectProxies := append([]string{"♻️ 自动选择"}, names...)
+	selectProxies = append(selectProxies, "DIRECT")
+
+	autoProxies := names
+	if len(autoProxies) == 0 {
+		autoProxies = []string{"DIRECT"}
+	}
+
+	cfg := clashConfig{
+		MixedPort: 7890,
+		Mode:      "rule",
+		LogLevel:  "info",
+		Proxies:   proxies,
+		ProxyGroups: []clashProxyGroup{
+			{Name: "🚀 节点选择", Type: "select", Proxies: selectProxies},
+			{Name: "♻️ 自动选择", Type: "url-test", Proxies: autoProxies, URL: "http://www.gstatic.com/generate_204", Interval: 300},
+		},
+		Rules: []string{
+			"GEOIP,LAN,DIRECT,no-resolve",
+			"GEOIP,CN,DIRECT",
+			"MATCH,🚀 节点选择",
+		},
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+// shadowsocksToClash 转换 shadowsocks 节点
+func shadowsocksToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "ss"
+	proxy["cipher"] = o.str("method")
+	proxy["password"] = o.str("password")
+	proxy["udp"] = true
+	if o.bool("udp_over_tcp") {
+		proxy["udp-over-tcp"] = true
+	}
+
+	switch plugin := o.str("plugin"); plugin {
+	case "":
+	case "obfs-local":
+		opts := parsePluginOpts(o.str("plugin_opts"))
+		pluginOpts := map[string]interface{}{"mode": opts["obfs"]}
+		if host := opts["obfs-host"]; host != "" {
+			pluginOpts["host"] = host
+		}
+		proxy["plugin"] = "obfs"
+		proxy["plugin-opts"] = pluginOpts
+	case "v2ray-plugin":
+		opts := parsePluginOpts(o.str("plugin_opts"))
+		pluginOpts := map[string]interface{}{"mode": "websocket"}
+		if opts["tls"] == "true" {
+			pluginOpts["tls"] = true
+		}
+		if host := opts["host"]; host != "" {
+			pluginOpts["host"] = host
+		}
+		if path := opts["path"]; path != "" {
+			pluginOpts["path"] = path
+		}
+		proxy["plugin"] = "v2ray-plugin"
+		proxy["plugin-opts"] = pluginOpts
+	default:
+		return fmt.Errorf("unsupported ss plugin: %s", plugin)
+	}
+	return nil
+}
+
+// vmessToClash 转换 vmess 节点
+func vmessToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "vmess"
+	proxy["uuid"] = o.str("uuid")
+	proxy["alterId"] = o.int("alter_id")
+	proxy["cipher"] = o.str("security")
+	if proxy["cipher"] == "" {
+		proxy["cipher"] = "auto"
+	}
+	proxy["udp"] = true
+
+	if o.tlsEnabled() {
+		proxy["tls"] = true
+		applyClashTLS(o, proxy, "servername")
+	}
+	return applyClashTransport(o, proxy)
+}
+
+// vlessToClash 转换 vless 节点
+func vlessToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "vless"
+	proxy["uuid"] = o.str("uuid")
+	proxy["udp"] = true
+	if flow := o.str("flow"); flow != "" {
+		proxy["flow"] = flow
+	}
+	if encoding := o.str("packet_encoding"); encoding != "" {
+		proxy["packet-encoding"] = encoding
+	}
+
+	if o.tlsEnabled() {
+		proxy["tls"] = true
+		applyClashTLS(o, proxy, "servername")
+	}
+	return applyClashTransport(o, proxy)
+}
+
+// trojanToClash 转换 trojan 节点
+func trojanToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "trojan"
+	proxy["password"] = o.str("password")
+	proxy["udp"] = true
+	applyClashTLS(o, proxy, "sni")
+	return applyClashTransport(o, proxy)
+}
+
+// hysteria2ToClash 转换 hysteria2 节点
+func hysteria2ToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "hysteria2"
+	proxy["password"] = o.str("password")
+
+	if serverPorts := o.strList("server_ports"); len(serverPorts) > 0 {
+		ports := make([]string, 0, len(serverPorts))
+		for _, r := range serverPorts {
+			ports = append(ports, strings.Replace(r, ":", "-", 1))
+		}
+		proxy["ports"] = strings.Join(ports, ",")
+	}
+
+	obfs := o.sub("obfs")
+	if obfsType := obfs.str("type"); obfsType != "" {
+		proxy["obfs"] = obfsType
+		proxy["obfs-password"] = obfs.str("password")
+	}
+	if up := o.int("up_mbps"); up > 0 {
+		proxy["up"] = up
+	}
+	if down := o.int("down_mbps"); down > 0 {
+		proxy["down"] = down
+	}
+
+	applyClashTLS(o, proxy, "sni")
+	return nil
+}
+
+// tuicToClash 转换 tuic 节点
+func tuicToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "tuic"
+	proxy["uuid"] = o.str("uuid")
+	proxy["password"] = o.str("password")
+	if cc := o.str("congestion_control"); cc != "" {
+		proxy["congestion-controller"] = cc
+	}
+	if mode := o.str("udp_relay_mode"); mode != "" {
+		proxy["udp-relay-mode"] = mode
+	}
+	if o.bool("zero_rtt_handshake") {
+		proxy["reduce-rtt"] = true
+	}
+
+	applyClashTLS(o, proxy, "sni")
+	if _, ok := proxy["sni"]; !ok {
+		proxy["disable-sni"] = true
+	}
+	return nil
+}
+
+// wireguardToClash 转换 wireguard 节点
+func wireguardToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "wireguard"
+	proxy["private-key"] = o.str("private_key")
+	proxy["public-key"] = o.str("peer_public_key")
+	proxy["udp"] = true
+
+	for _, addr := range o.strList("local_address") {
+		ip, _, _ := strings.Cut(addr, "/")
+		if strings.Contains(ip, ":") {
+			proxy["ipv6"] = ip
+		} else {
+			proxy["ip"] = ip
+		}
+	}
+	if _, ok := proxy["ip"]; !ok {
+		return fmt.Errorf("missing ipv4 local_address")
+	}
+
+	if psk := o.str("pre_shared_key"); psk != "" {
+		proxy["pre-shared-key"] = psk
+	}
+	if reserved := o.intList("reserved"); len(reserved) > 0 {
+		proxy["reserved"] = reserved
+	}
+	if mtu := o.int("mtu"); mtu > 0 {
+		proxy["mtu"] = mtu
+	}
+	return nil
+}
+
+// socksToClash 转换 socks 节点
+func socksToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "socks5"
+	proxy["udp"] = true
+	if username := o.str("username"); username != "" {
+		proxy["username"] = username
+		proxy["password"] = o.str("password")
+	}
+	return nil
+}
+
+// httpToClash 转换 http 节点
+func httpToClash(o outbound, proxy map[string]interface{}) error {
+	proxy["type"] = "http"
+	if username := o.str("username"); username != "" {
+		proxy["username"] = username
+		proxy["password"] = o.str("password")
+	}
+	if o.tlsEnabled() {
+		proxy["tls"] = true
+		applyClashTLS(o, proxy, "sni")
+	}
+	return nil
+}
+
+// applyClashTLS 写入 TLS 相关字段，sniKey 为目标类型使用的 SNI 字段名
+func applyClashTLS(o outbound, proxy map[string]interface{}, sniKey string) {
+	tls := o.sub("tls")
+	if serverName := tls.str("server_name"); serverName != "" {
+		proxy[sniKey] = serverName
+	}
+	if tls.bool("insecure") {
+		proxy["skip-cert-verify"] = true
+	}
+	if alpn := tls.strList("alpn"); len(alpn) > 0 {
+		proxy["alpn"] = alpn
+	}
+	if utls := tls.sub("utls"); utls.bool("enabled") {
+		proxy["client-fingerprint"] = utls.str("fingerprint")
+	}
+	if reality := tls.sub("reality"); reality.bool("enabled") {
+		realityOpts := map[string]interface{}{
+			"public-key": reality.str("public_key"),
+		}
+		if shortID := reality.str("short_id"); shortID != "" {
+			realityOpts["short-id"] = shortID
+		}
+		proxy["reality-opts"] = realityOpts
+	}
+}
+
+// applyClashTransport 写入 network 及对应的 *-opts 字段
+func applyClashTransport(o outbound, proxy map[string]interface{}) error {
+	if !o.has("transport") {
+		return nil
+	}
+
+	transport := o.sub("transport")
+	switch transportType := transport.str("type"); transportType {
+	case "ws":
+		wsOpts := map[string]interface{}{}
+		if path := transport.str("path"); path != "" {
+			wsOpts["path"] = path
+		}
+		if host := transport.sub("headers").str("Host"); host != "" {
+			wsOpts["headers"] = map[string]interface{}{"Host": host}
+		}
+		if ed := transport.int("max_early_data"); ed > 0 {
+			wsOpts["max-early-data"] = ed
+			wsOpts["early-data-header-name"] = transport.str("early_data_header_name")
+		}
+		proxy["network"] = "ws"
+		proxy["ws-opts"] = wsOpts
+
+	case "httpupgrade":
+		wsOpts := map[string]interface{}{"v2ray-http-upgrade": true}
+		if path := transport.str("path"); path != "" {
+			wsOpts["path"] = path
+		}
+		if host := transport.str("host"); host != "" {
+			wsOpts["headers"] = map[string]interface{}{"Host": host}
+		}
+		proxy["network"] = "ws"
+		proxy["ws-opts"] = wsOpts
+
+	case "grpc":
+		proxy["network"] = "grpc"
+		proxy["grpc-opts"] = map[string]interface{}{
+			"grpc-service-name": transport.str("service_name"),
+		}
+
+	case "http":
+		hosts := transport.strList("host")
+		path := transport.str("path")
+		// sing-box 的 http 传输在 TLS 下为 h2，否则为 HTTP/1.1 伪装
+		if o.tlsEnabled() {
+			h2Opts := map[string]interface{}{}
+			if len(hosts) > 0 {
+				h2Opts["host"] = hosts
+			}
+			if path != "" {
+				h2Opts["path"] = path
+			}
+			proxy["network"] = "h2"
+			proxy["h2-opts"] = h2Opts
+		} else {
+			httpOpts := map[string]interface{}{}
+			if path != "" {
+				httpOpts["path"] = []string{path}
+			}
+			if len(hosts) > 0 {
+				httpOpts["headers"] = map[string]interface{}{"Host": hosts}
+			}
+			proxy["network"] = "http"
+			proxy["http-opts"] = httpOpts
+		}
+
+	default:
+		return fmt.Errorf("unsupported transport: %s", transportType)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// outbound 提供对 sing-box outbound（JSON 反序列化得到的 map）的类型化读取
+type outbound map[string]interface{}
+
+// str 读取字符串字段
+func (o outbound) str(key string) string {
+	switch v := o[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// int 读取整数字段
+func (o outbound) int(key string) int {
+	switch v := o[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	default:
+		return 0
+	}
+}
+
+// bool 读取布尔字段
+func (o outbound) bool(key string) bool {
+	v, _ := o[key].(bool)
+	return v
+}
+
+// sub 读取嵌套对象字段，不存在时返回空对象
+func (o outbound) sub(key string) outbound {
+	if m, ok := o[key].(map[string]interface{}); ok {
+		return outbound(m)
+	}
+	return outbound{}
+}
+
+// has 判断字段是否存在
+func (o outbound) has(key string) bool {
+	_, ok := o[key]
+	return ok
+}
+
+// strList 读取字符串列表字段，兼容单个字符串
+func (o outbound) strList(key string) []string {
+	switch v := o[key].(type) {
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, outbound{"v": item}.str("v"))
+		}
+		return list
+	case []string:
+		return v
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	default:
+		return nil
+	}
+}
+
+// intList 读取整数列表字段
+func (o outbound) intList(key string) []int {
+	v, ok := o[key].([]interface{})
+	if !ok {
+		if ints, ok := o[key].([]int); ok {
+			return ints
+		}
+		return nil
+	}
+	list := make([]int, 0, len(v))
+	for _, item := range v {
+		list = append(list, outbound{"v": item}.int("v"))
+	}
+	return list
+}
+
+// tlsEnabled 判断 outbound 是否启用了 TLS
+func (o outbound) tlsEnabled() bool {
+	return o.sub("tls").bool("enabled")
+}
+
+// parsePluginOpts 解析 SIP003 插件参数 "k=v;flag;k2=v2"
+func parsePluginOpts(opts string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range strings.Split(opts, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			v = "true"
+		}
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,318 @@
+package exporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// uriConverter 单个 sing-box 出站类型到分享链接的转换函数
+type uriConverter func(o outbound) (string, error)
+
+// uriConverters sing-box outbound type -> 转换函数
+var uriConverters = map[string]uriConverter{
+	"shadowsocks": shadowsocksToURI,
+	"vmess":       vmessToURI,
+	"vless":       vlessToURI,
+	"trojan":      trojanToURI,
+	"hysteria2":   hysteria2ToURI,
+	"tuic":        tuicToURI,
+}
+
+// ToURI 将单个 sing-box outbound 转换为标准分享链接
+func ToURI(node map[string]interface{}) (string, error) {
+	o := outbound(node)
+	convert, ok := uriConverters[o.str("type")]
+	if !ok {
+		return "", fmt.Errorf("unsupported type: %s", o.str("type"))
+	}
+	return convert(o)
+}
+
+// ToURIs 批量转换节点，无法转换的节点跳过并以 warnings 返回
+func ToURIs(nodes []map[string]interface{}) ([]string, []string) {
+	links := make([]string, 0, len(nodes))
+	var warnings []string
+
+	for _, node := range nodes {
+		link, err := ToURI(node)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", outbound(node).str("tag"), err))
+			continue
+		}
+		links = append(links, link)
+	}
+	return links, warnings
+}
+
+// shadowsocksToURI 输出 SIP002 格式的 ss:// 链接
+func shadowsocksToURI(o outbound) (string, error) {
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte(o.str("method") + ":" + o.str("password")))
+
+	query := url.Values{}
+	if plugin := o.str("plugin"); plugin != "" {
+		if opts := o.str("plugin_opts"); opts != "" {
+			plugin += ";" + opts
+		}
+		query.Set("plugin", plugin)
+	}
+
+	link := "ss://" + userInfo + "@" + hostPort(o)
+	if len(query) > 0 {
+		link += "/?" + query.Encode()
+	}
+	return link + fragment(o), nil
+}
+
+// vmessToURI 输出 v2rayN 格式的 vmess://base64(json) 链接
+func vmessToURI(o outbound) (string, error) {
+	v := map[string]string{
+		"v":    "2",
+		"ps":   o.str("tag"),
+		"add":  o.str("server"),
+		"port": strconv.Itoa(o.int("server_port")),
+		"id":   o.str("uuid"),
+		"aid":  strconv.Itoa(o.int("alter_id")),
+		"scy":  o.str("security"),
+		"net":  "tcp",
+		"type": "none",
+	}
+	if v["scy"] == "" {
+		v["scy"] = "auto"
+	}
+
+	if o.tlsEnabled() {
+		tls := o.sub("tls")
+		v["tls"] = "tls"
+		v["sni"] = tls.str("server_name")
+		v["alpn"] = strings.Join(tls.strList("alpn"), ",")
+		v["fp"] = tls.sub("utls").str("fingerprint")
+		if tls.bool("insecure") {
+			v["allowInsecure"] = "1"
+		}
+	}
+
+	query, err := transportQuery(o)
+	if err != nil {
+		return "", err
+	}
+	if t := query.Get("type"); t != "" {
+		v["net"] = t
+	}
+	if query.Get("headerType") != "" {
+		v["type"] = query.Get("headerType")
+	}
+	v["host"] = query.Get("host")
+	v["path"] = query.Get("path")
+	if v["net"] == "grpc" {
+		v["path"] = query.Get("serviceName")
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// vlessToURI 输出 vless:// 链接
+func vlessToURI(o outbound) (string, error) {
+	query, err := transportQuery(o)
+	if err != nil {
+		return "", err
+	}
+	query.Set("encryption", "none")
+	if flow := o.str("flow"); flow != "" {
+		query.Set("flow", flow)
+	}
+	if encoding := o.str("packet_encoding"); encoding != "" {
+		query.Set("packetEncoding", encoding)
+	}
+	applyTLSQuery(o, query)
+
+	return "vless://" + url.PathEscape(o.str("uuid")) + "@" + hostPort(o) + "?" + query.Encode() + fragment(o), nil
+}
+
+// trojanToURI 输出 trojan:// 链接
+func trojanToURI(o outbound) (string, error) {
+	query, err := transportQuery(o)
+	if err != nil {
+		return "", err
+	}
+	applyTLSQuery(o, query)
+
+	return "trojan://" + url.PathEscape(o.str("password")) + "@" + hostPort(o) + "?" + query.Encode() + fragment(o), nil
+}
+
+// hysteria2ToURI 输出 hysteria2:// 链接
+func hysteria2ToURI(o outbound) (string, error) {
+	query := url.Values{}
+	tls := o.sub("tls")
+	if sni := tls.str("server_name"); sni != "" {
+		query.Set("sni", sni)
+	}
+	if tls.bool("insecure") {
+		query.Set("insecure", "1")
+	}
+	if alpn := tls.strList("alpn"); len(alpn) > 0 {
+		query.Set("alpn", strings.Join(alpn, ","))
+	}
+
+	obfs := o.sub("obfs")
+	if obfsType := obfs.str("type"); obfsType != "" {
+		query.Set("obfs", obfsType)
+		query.Set("obfs-password", obfs.str("password"))
+	}
+
+	if serverPorts := o.strList("server_ports"); len(serverPorts) > 0 {
+		ports := make([]string, 0, len(serverPorts))
+		for _, r := range serverPorts {
+			ports = append(ports, strings.Replace(r, ":", "-", 1))
+		}
+		query.Set("mport", strings.Join(ports, ","))
+	}
+	if up := o.int("up_mbps"); up > 0 {
+		query.Set("upmbps", strconv.Itoa(up))
+	}
+	if down := o.int("down_mbps"); down > 0 {
+		query.Set("downmbps", strconv.Itoa(down))
+	}
+
+	return "hysteria2://" + url.PathEscape(o.str("password")) + "@" + hostPort(o) + "/?" + query.Encode() + fragment(o), nil
+}
+
+// tuicToURI 输出 tuic:// 链接
+func tuicToURI(o outbound) (string, error) {
+	query := url.Values{}
+	if cc := o.str("congestion_control"); cc != "" {
+		query.Set("congestion_control", cc)
+	}
+	if mode := o.str("udp_relay_mode"); mode != "" {
+		query.Set("udp_relay_mode", mode)
+	}
+	if o.bool("zero_rtt_handshake") {
+		query.Set("reduce_rtt", "1")
+	}
+
+	tls := o.sub("tls")
+	if sni := tls.str("server_name"); sni != "" {
+		query.Set("sni", sni)
+	} else {
+		query.Set("disable_sni", "1")
+	}
+	if tls.bool("insecure") {
+		query.Set("allow_insecure", "1")
+	}
+	if alpn := tls.strList("alpn"); len(alpn) > 0 {
+		query.Set("alpn", strings.Join(alpn, ","))
+	}
+
+	userInfo := url.UserPassword(o.str("uuid"), o.str("password")).String()
+	return "tuic://" + userInfo + "@" + hostPort(o) + "?" + query.Encode() + fragment(o), nil
+}
+
+// applyTLSQuery 写入 vless / trojan 风格的 TLS 查询参数
+func applyTLSQuery(o outbound, query url.Values) {
+	if !o.tlsEnabled() {
+		query.Set("security", "none")
+		return
+	}
+
+	tls := o.sub("tls")
+	query.Set("security", "tls")
+	if sni := tls.str("server_name"); sni != "" {
+		query.Set("sni", sni)
+	}
+	if tls.bool("insecure") {
+		query.Set("allowInsecure", "1")
+	}
+	if alpn := tls.strList("alpn"); len(alpn) > 0 {
+		query.Set("alpn", strings.Join(alpn, ","))
+	}
+	if utls := tls.sub("utls"); utls.bool("enabled") {
+		query.Set("fp", utls.str("fingerprint"))
+	}
+	if reality := tls.sub("reality"); reality.bool("enabled") {
+		query.Set("security", "reality")
+		query.Set("pbk", reality.str("public_key"))
+		if shortID := reality.str("short_id"); shortID != "" {
+			query.Set("sid", shortID)
+		}
+	}
+}
+
+// transportQuery 将 transport 转换为 type / host / path / serviceName 查询参数
+func transportQuery(o outbound) (url.Values, error) {
+	query := url.Values{}
+	if !o.has("transport") {
+		query.Set("type", "tcp")
+		return query, nil
+	}
+
+	transport := o.sub("transport")
+	switch transportType := transport.str("type"); transportType {
+	case "ws":
+		query.Set("type", "ws")
+		path := transport.str("path")
+		if ed := transport.int("max_early_data"); ed > 0 {
+			path += "?ed=" + strconv.Itoa(ed)
+		}
+		if path != "" {
+			query.Set("path", path)
+		}
+		if host := transport.sub("headers").str("Host"); host != "" {
+			query.Set("host", host)
+		}
+
+	case "httpupgrade":
+		query.Set("type", "httpupgrade")
+		if path := transport.str("path"); path != "" {
+			query.Set("path", path)
+		}
+		if host := transport.str("host"); host != "" {
+			query.Set("host", host)
+		}
+
+	case "grpc":
+		query.Set("type", "grpc")
+		if serviceName := transport.str("service_name"); serviceName != "" {
+			query.Set("serviceName", serviceName)
+		}
+
+	case "http":
+		// sing-box 的 http 传输在 TLS 下为 h2，否则为 tcp + http 伪装
+		if o.tlsEnabled() {
+			query.Set("type", "http")
+		} else {
+			query.Set("type", "tcp")
+			query.Set("headerType", "http")
+		}
+		if hosts := transport.strList("host"); len(hosts) > 0 {
+			query.Set("host", strings.Join(hosts, ","))
+		}
+		if path := transport.str("path"); path != "" {
+			query.Set("path", path)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", transportType)
+	}
+	return query, nil
+}
+
+// hostPort 输出 server:port，IPv6 地址自动加方括号
+func hostPort(o outbound) string {
+	return net.JoinHostPort(o.str("server"), strconv.Itoa(o.int("server_port")))
+}
+
+// fragment 输出链接末尾的 #tag
+func fragment(o outbound) string {
+	if tag := o.str("tag"); tag != "" {
+		return "#" + url.PathEscape(tag)
+	}
+	return ""
+}
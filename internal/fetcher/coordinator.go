@@ -0,0 +1,74 @@
+package fetcher
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// fetchRecord 最近一次拉取的结果
+type fetchRecord struct {
+	at  time.Time
+	err error
+}
+
+var (
+	flight      singleflight.Group
+	recordMutex sync.Mutex
+	records     = make(map[string]fetchRecord) // 拉取目标 -> 最近一次拉取结果
+)
+
+// coordinate 合并同一目标的并发拉取，所有等待方共享同一次结果；
+// 距上次拉取不足冷却时间时不再请求上游，直接返回上次的错误，且视为内容未变化
+func coordinate(key string, fetch func() (bool, error)) (bool, error) {
+	if record, ok := recentRecord(key); ok {
+		return false, record.err
+	}
+
+	result, err, shared := flight.Do(key, func() (interface{}, error) {
+		// 等待期间其他调用方可能刚完成拉取
+		if record, ok := recentRecord(key); ok {
+			return false, record.err
+		}
+
+		changed, err := fetch()
+		recordMutex.Lock()
+		records[key] = fetchRecord{at: time.Now(), err: err}
+		recordMutex.Unlock()
+		return changed, err
+	})
+	if shared {
+		logger.Debug("Joined in-flight fetch", zap.String("target", key))
+	}
+	return result.(bool), err
+}
+
+// recentRecord 返回冷却时间内的上次拉取结果
+func recentRecord(key string) (fetchRecord, bool) {
+	cooldown := cfg.GetRefreshCooldown()
+	if cooldown <= 0 {
+		return fetchRecord{}, false
+	}
+
+	recordMutex.Lock()
+	record, ok := records[key]
+	recordMutex.Unlock()
+	if !ok || time.Since(record.at) >= cooldown {
+		return fetchRecord{}, false
+	}
+
+	logger.Debug("Fetch skipped, within refresh cooldown",
+		zap.String("target", key),
+		zap.Duration("since_last_fetch", time.Since(record.at)),
+	)
+	return record, true
+}
+
+// resetRecords 清空拉取记录，配置重载后按新配置重新拉取
+func resetRecords() {
+	recordMutex.Lock()
+	records = make(map[string]fetchRecord)
+	recordMutex.Unlock()
+}
@@ -1,101 +1,301 @@
 package fetcher
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/snapshot"
 )
 
 var (
-	cfg        *global.Config
-	logger     *zap.Logger
-	httpClient *http.Client
+	cfg    *global.Config
+	logger *zap.Logger
 )
 
 // Init 初始化 fetcher
 func Init(c *global.Config, l *zap.Logger) {
 	cfg = c
 	logger = l
+	resetRecords()
+	resetBreakers()
+	resetClients()
+}
 
-	httpClient = &http.Client{
-		Timeout: cfg.GetRequestTimeout(),
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: false,
-			},
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-	}
+// target 一次拉取的目标
+type target struct {
+	name        string // 上游名称，用于重试日志与熔断状态
+	url         string
+	cachePath   string
+	timeout     time.Duration // 单次请求超时
+	cacheBuster bool          // 是否追加 _t 随机参数绕过 CDN 缓存
+	retry       global.RetryConfig
+	breaker     global.BreakerConfig
+	transport   global.Transport // 代理与 TLS 设置
+	request     global.Request   // 请求头、查询参数与认证信息
+	validate    contentValidator // 可选，校验下载内容
+}
+
+// response 一次成功请求的结果
+type response struct {
+	notModified  bool
+	data         []byte
+	etag         string
+	lastModified string
 }
 
-// fetchFile 从 URL 获取文件并保存
-func fetchFile(url, cachePath string) error {
-	// 添加随机数参数以绕过 CDN 缓存
-	urlWithParam := addCacheBusterParam(url)
-	logger.Info("Fetching file from %s", zap.String("url", urlWithParam))
+// download 发起一次请求，缓存文件存在时携带上次响应的 ETag / Last-Modified 发起条件请求
+func download(client *http.Client, t target, fetchURL string, header http.Header, meta cacheMeta) (response, error) {
+	if t.cacheBuster {
+		// 添加随机数参数以绕过 CDN 缓存
+		fetchURL = addCacheBusterParam(fetchURL)
+	}
+	// 查询参数可能包含密钥，日志中只记录配置的地址
+	logger.Info("Fetching file from %s", zap.String("url", t.url))
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
 
-	req, err := http.NewRequest("GET", urlWithParam, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fetchURL, nil)
 	if err != nil {
-		return fmt.Errorf("create request error: %w", err)
+		return response{}, fmt.Errorf("create request error: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Singbox-Subscribe-Convert/1.0")
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	req.Header.Set("Pragma", "no-cache")
-	req.Header.Set("Expires", "0")
+	req.Header = header.Clone()
+	// no-cache 会让部分 CDN 直接回源且不再返回 304，仅在开启 cache_buster 时发送
+	if t.cacheBuster {
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
 
-	resp, err := httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("fetch error: %w", err)
+		return response{}, fmt.Errorf("fetch error: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return response{notModified: true}, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("fetch failed with status: %d", resp.StatusCode)
+		return response{}, &statusError{code: resp.StatusCode}
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("read response error: %w", err)
+		return response{}, fmt.Errorf("read response error: %w", err)
 	}
 
 	if len(data) == 0 {
-		return fmt.Errorf("received empty file")
+		return response{}, fmt.Errorf("received empty file")
+	}
+
+	return response{
+		data:         data,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// fetchFile 从 URL 获取文件，校验通过后原子写入缓存，返回缓存内容是否发生变化
+// 请求失败时按重试策略重试，连续失败达到阈值后熔断；上游返回 304 或缓存已回滚固定时不写入缓存
+func fetchFile(t target) (bool, error) {
+	if pin, ok := LoadPin(t.cachePath); ok {
+		logger.Info("Cache file pinned, skip fetching",
+			zap.String("target", t.name),
+			zap.String("snapshot", pin.Snapshot),
+		)
+		return false, nil
+	}
+
+	client, err := clientFor(t.transport)
+	if err != nil {
+		return false, err
+	}
+	fetchURL, header, err := prepareRequest(t)
+	if err != nil {
+		return false, err
+	}
+	trial, err := breakerAllow(t.name)
+	if err != nil {
+		return false, err
+	}
+	policy := t.retry
+	if trial {
+		policy.MaxAttempts = 1
 	}
 
-	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
-		return fmt.Errorf("create cache dir error: %w", err)
+	meta := loadMeta(t.cachePath, t.url)
+	var resp response
+	err = withRetry(t.name, policy, func() error {
+		var err error
+		resp, err = download(client, t, fetchURL, header, meta)
+		return err
+	})
+	breakerRecord(t.name, t.breaker, err)
+	if err != nil {
+		return false, err
 	}
 
-	if err := os.WriteFile(cachePath, data, 0644); err != nil {
-		return fmt.Errorf("write cache file error: %w", err)
+	if resp.notModified {
+		logger.Info("Upstream not modified, skip writing", zap.String("cachePath", t.cachePath))
+		return false, nil
 	}
 
-	logger.Info("Successfully fetched and cached: %s (%d bytes)", zap.String("cachePath", cachePath), zap.Int("len", len(data)))
-	return nil
+	if t.validate != nil {
+		if err := t.validate(resp.data); err != nil {
+			return false, err
+		}
+	}
+
+	changed, err := commitFile(t.cachePath, resp.data)
+	if err != nil {
+		return false, err
+	}
+
+	// 缓存内容与上游一致后再保存校验信息
+	saveMeta(t.cachePath, cacheMeta{
+		URL:          t.url,
+		ETag:         resp.etag,
+		LastModified: resp.lastModified,
+	})
+
+	if !changed {
+		logger.Info("Fetched file unchanged, skip writing", zap.String("cachePath", t.cachePath))
+		return false, nil
+	}
+
+	if err := snapshot.Save(t.cachePath, resp.data); err != nil {
+		logger.Warn("Failed to save snapshot",
+			zap.String("cachePath", t.cachePath),
+			zap.Error(err),
+		)
+	}
+
+	logger.Info("Successfully fetched and cached: %s (%d bytes)", zap.String("cachePath", t.cachePath), zap.Int("len", len(resp.data)))
+	return true, nil
 }
 
-// FetchNodeFile 获取节点文件
-func FetchNodeFile() error {
-	return fetchFile(cfg.Subscription.URL, cfg.GetNodeFilePath())
+// FetchSubscription 获取单个订阅源的节点文件，返回缓存是否发生变化；同一订阅源的并发请求只拉取一次
+func FetchSubscription(sub global.SubscriptionConfig) (bool, error) {
+	return coordinate("subscription:"+sub.Name, func() (bool, error) {
+		return fetchFile(target{
+			name:        "subscription:" + sub.Name,
+			url:         sub.URL,
+			cachePath:   cfg.GetSubscriptionFilePathByName(sub.Name),
+			timeout:     sub.GetTimeout(),
+			cacheBuster: sub.CacheBuster,
+			retry:       sub.Retry,
+			breaker:     sub.CircuitBreaker,
+			transport:   sub.Transport,
+			request:     sub.Request,
+			validate:    subscriptionValidator(sub),
+		})
+	})
+}
+
+// FetchAllSubscriptions 获取所有启用的订阅源节点文件，返回是否有订阅源发生变化
+// 单个订阅源失败不影响其他订阅源，返回的 map 中仅包含失败的订阅源
+func FetchAllSubscriptions() (bool, map[string]error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		changed bool
+		errors  = make(map[string]error)
+	)
+
+	for _, sub := range cfg.GetEnabledSubscriptions() {
+		wg.Add(1)
+		go func(sub global.SubscriptionConfig) {
+			defer wg.Done()
+			subChanged, err := FetchSubscription(sub)
+			if err != nil {
+				logger.Error("Failed to fetch subscription",
+					zap.String("subscription", sub.Name),
+					zap.String("url", sub.URL),
+					zap.Error(err),
+				)
+				mu.Lock()
+				errors[sub.Name] = err
+				mu.Unlock()
+				return
+			}
+
+			logger.Info("Successfully fetched subscription",
+				zap.String("subscription", sub.Name),
+				zap.Bool("changed", subChanged),
+			)
+			if subChanged {
+				mu.Lock()
+				changed = true
+				mu.Unlock()
+			}
+		}(sub)
+	}
+
+	wg.Wait()
+	return changed, errors
 }
 
 // FetchTemplateFileByName 根据模板名称获取模板文件
-func FetchTemplateFileByName(templateName string, templateURL string) error {
-	cachePath := cfg.GetTemplateFilePathByName(templateName)
-	return fetchFile(templateURL, cachePath)
+func FetchTemplateFileByName(templateName string, templateURL string) (bool, error) {
+	tpl, _ := cfg.GetTemplate(templateName)
+	return fetchFile(target{
+		name:        "template:" + templateName,
+		url:         templateURL,
+		cachePath:   cfg.GetTemplateFilePathByName(templateName),
+		timeout:     cfg.GetRequestTimeout(),
+		cacheBuster: tpl.CacheBuster,
+		retry:       cfg.Subscription.Retry,
+		breaker:     cfg.Subscription.CircuitBreaker,
+		transport:   cfg.Subscription.Transport,
+		request:     tpl.Request,
+		validate:    templateValidator(tpl.IsStructured()),
+	})
+}
+
+// FetchTemplate 获取模板文件，配置了 clash_url 时一并获取 Clash 模板，返回缓存是否发生变化；
+// 同一模板的并发请求只拉取一次
+func FetchTemplate(templateName string, tpl global.TemplateConfig) (bool, error) {
+	return coordinate("template:"+templateName, func() (bool, error) {
+		changed, err := FetchTemplateFileByName(templateName, tpl.URL)
+		if err != nil {
+			return false, err
+		}
+		if tpl.ClashURL != "" {
+			clashChanged, err := fetchFile(target{
+				name:        "clash_template:" + templateName,
+				url:         tpl.ClashURL,
+				cachePath:   cfg.GetClashTemplateFilePathByName(templateName),
+				timeout:     cfg.GetRequestTimeout(),
+				cacheBuster: tpl.CacheBuster,
+				retry:       cfg.Subscription.Retry,
+				breaker:     cfg.Subscription.CircuitBreaker,
+				transport:   cfg.Subscription.Transport,
+				request:     tpl.Request,
+				validate:    templateValidator(false),
+			})
+			if err != nil {
+				return changed, fmt.Errorf("clash template: %w", err)
+			}
+			changed = changed || clashChanged
+		}
+		return changed, nil
+	})
 }
 
 // FetchAllTemplates 获取所有启用的模板文件
@@ -105,7 +305,7 @@ func FetchAllTemplates() map[string]error {
 	// 获取所有启用的模板
 	enabledTemplates := cfg.GetEnabledTemplates()
 	for name, tpl := range enabledTemplates {
-		if err := FetchTemplateFileByName(name, tpl.URL); err != nil {
+		if _, err := FetchTemplate(name, tpl); err != nil {
 			logger.Error("Failed to fetch template",
 				zap.String("template", name),
 				zap.String("url", tpl.URL),
@@ -122,9 +322,15 @@ func FetchAllTemplates() map[string]error {
 	return errors
 }
 
-// CheckCacheExists 检查缓存是否存在
+// CheckCacheExists 检查缓存是否存在（任一订阅源缓存存在即可）
 func CheckCacheExists() bool {
-	nodeExists := fileExists(cfg.GetNodeFilePath())
+	nodeExists := false
+	for _, sub := range cfg.GetEnabledSubscriptions() {
+		if fileExists(cfg.GetSubscriptionFilePathByName(sub.Name)) {
+			nodeExists = true
+			break
+		}
+	}
 	defaultTemplatePath := cfg.GetTemplateFilePathByName(cfg.DefaultTemplate)
 	return nodeExists && fileExists(defaultTemplatePath)
 }
@@ -0,0 +1,69 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/snapshot"
+)
+
+// Pin 回滚后固定的缓存文件：固定期间自动更新跳过该文件，直到管理员解除固定或手动刷新
+type Pin struct {
+	Snapshot string    `json:"snapshot"`
+	PinnedAt time.Time `json:"pinned_at"`
+}
+
+// pinFilePath 缓存文件固定标记的路径，使用文件保存以便 snapshot 命令与运行中的服务共享
+func pinFilePath(path string) string {
+	return path + ".pin"
+}
+
+// LoadPin 读取缓存文件的固定标记
+func LoadPin(path string) (Pin, bool) {
+	data, err := os.ReadFile(pinFilePath(path))
+	if err != nil {
+		return Pin{}, false
+	}
+	var pin Pin
+	if err := json.Unmarshal(data, &pin); err != nil {
+		return Pin{}, false
+	}
+	return pin, true
+}
+
+// savePin 保存缓存文件的固定标记
+func savePin(path string, pin Pin) error {
+	data, _ := json.Marshal(pin)
+	return writeFileAtomic(pinFilePath(path), data)
+}
+
+// Unpin 解除缓存文件的固定，返回之前是否处于固定状态
+func Unpin(target snapshot.Target) (bool, error) {
+	if _, ok := LoadPin(target.Path); !ok {
+		return false, nil
+	}
+	if err := os.Remove(pinFilePath(target.Path)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	logger.Info("Unpinned cache file",
+		zap.String("kind", target.Kind),
+		zap.String("name", target.Name),
+	)
+	return true, nil
+}
+
+// UnpinAll 解除所有订阅源与模板的固定，手动刷新前调用
+func UnpinAll() {
+	for _, target := range snapshot.Targets() {
+		if _, err := Unpin(target); err != nil {
+			logger.Warn("Failed to unpin cache file",
+				zap.String("kind", target.Kind),
+				zap.String("name", target.Name),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package fetcher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+)
+
+// prepareRequest 根据请求设置生成拉取地址与请求头，env: / file: 形式的取值在每次拉取时重新读取
+func prepareRequest(t target) (string, http.Header, error) {
+	fetchURL := t.url
+	if len(t.request.Query) > 0 {
+		u, err := url.Parse(t.url)
+		if err != nil {
+			return "", nil, fmt.Errorf("parse url error: %w", err)
+		}
+		query := u.Query()
+		for key, value := range t.request.Query {
+			resolved, err := global.ResolveSecret(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("query %s: %w", key, err)
+			}
+			query.Set(key, resolved)
+		}
+		u.RawQuery = query.Encode()
+		fetchURL = u.String()
+	}
+
+	header := make(http.Header)
+	header.Set("User-Agent", t.request.GetUserAgent())
+	header.Set("Accept", "*/*")
+	for key, value := range t.request.Headers {
+		resolved, err := global.ResolveSecret(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("header %s: %w", key, err)
+		}
+		header.Set(key, resolved)
+	}
+
+	auth := t.request.Auth
+	switch auth.Type {
+	case global.UpstreamAuthBasic:
+		username, err := global.ResolveSecret(auth.Username)
+		if err != nil {
+			return "", nil, fmt.Errorf("auth username: %w", err)
+		}
+		password, err := global.ResolveSecret(auth.Password)
+		if err != nil {
+			return "", nil, fmt.Errorf("auth password: %w", err)
+		}
+		credential := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		header.Set("Authorization", "Basic "+credential)
+	case global.UpstreamAuthBearer:
+		token, err := global.ResolveSecret(auth.Token)
+		if err != nil {
+			return "", nil, fmt.Errorf("auth token: %w", err)
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	return fetchURL, header, nil
+}
@@ -0,0 +1,188 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen 上游处于熔断状态，本次未发起请求
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// statusError 上游返回非预期的 HTTP 状态码
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("fetch failed with status: %d", e.code)
+}
+
+// retryable 判断下载错误是否可以重试：网络错误、超时以及配置的状态码
+func retryable(err error, policy global.RetryConfig) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return policy.IsRetryableStatus(se.code)
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// backoffWait 计算第 attempt 次重试前的等待时间，在基础等待时间上加入 ±jitter 的随机抖动
+func backoffWait(policy global.RetryConfig, attempt int) time.Duration {
+	wait := policy.GetBackoff(attempt)
+	if jitter := policy.GetJitter(); jitter > 0 {
+		wait = time.Duration(float64(wait) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	return wait
+}
+
+// withRetry 按重试策略执行 fn，不可重试的错误立即返回
+func withRetry(name string, policy global.RetryConfig, fn func() error) error {
+	maxAttempts := policy.GetMaxAttempts()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts || !retryable(err, policy) {
+			return err
+		}
+
+		wait := backoffWait(policy, attempt)
+		logger.Warn("Fetch failed, retrying",
+			zap.String("target", name),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts),
+			zap.Duration("wait", wait),
+			zap.Error(err),
+		)
+		time.Sleep(wait)
+	}
+}
+
+// breaker 单个上游的熔断状态
+type breaker struct {
+	failures    int // 连续失败次数
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+	openUntil   time.Time
+}
+
+var (
+	breakerMutex sync.Mutex
+	breakers     = make(map[string]*breaker)
+)
+
+// breakerAllow 检查上游是否允许请求；熔断冷却结束后放行一次试探请求，trial 为 true 时不再重试
+func breakerAllow(name string) (trial bool, err error) {
+	breakerMutex.Lock()
+	defer breakerMutex.Unlock()
+
+	b, ok := breakers[name]
+	if !ok || b.openUntil.IsZero() {
+		return false, nil
+	}
+	if wait := time.Until(b.openUntil); wait > 0 {
+		return false, fmt.Errorf("%w: retry in %s (last error: %s)", ErrCircuitOpen, wait.Round(time.Second), b.lastError)
+	}
+	return true, nil
+}
+
+// breakerRecord 记录一次拉取结果，连续失败达到阈值时熔断
+func breakerRecord(name string, policy global.BreakerConfig, err error) {
+	breakerMutex.Lock()
+	defer breakerMutex.Unlock()
+
+	b, ok := breakers[name]
+	if !ok {
+		b = &breaker{}
+		breakers[name] = b
+	}
+
+	now := time.Now()
+	if err == nil {
+		if b.failures >= policy.GetThreshold() && policy.GetThreshold() > 0 {
+			logger.Info("Circuit breaker closed", zap.String("target", name))
+		}
+		b.failures = 0
+		b.lastSuccess = now
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	b.lastFailure = now
+	if threshold := policy.GetThreshold(); threshold > 0 && b.failures >= threshold {
+		b.openUntil = now.Add(policy.GetCooldown())
+		logger.Warn("Circuit breaker opened",
+			zap.String("target", name),
+			zap.Int("consecutive_failures", b.failures),
+			zap.Time("open_until", b.openUntil),
+		)
+	}
+}
+
+// resetBreakers 清空熔断状态，配置重载后重新计算
+func resetBreakers() {
+	breakerMutex.Lock()
+	breakers = make(map[string]*breaker)
+	breakerMutex.Unlock()
+}
+
+// UpstreamStatus 上游拉取状态，用于健康检查
+type UpstreamStatus struct {
+	Target              string `json:"target"`
+	State               string `json:"state"` // closed / open / half_open
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error,omitempty"`
+	LastFailure         string `json:"last_failure,omitempty"`
+	LastSuccess         string `json:"last_success,omitempty"`
+	OpenUntil           string `json:"open_until,omitempty"`
+}
+
+// UpstreamStatuses 返回所有已拉取过的上游状态，按名称排序
+func UpstreamStatuses() []UpstreamStatus {
+	breakerMutex.Lock()
+	defer breakerMutex.Unlock()
+
+	now := time.Now()
+	statuses := make([]UpstreamStatus, 0, len(breakers))
+	for name, b := range breakers {
+		status := UpstreamStatus{
+			Target:              name,
+			State:               "closed",
+			ConsecutiveFailures: b.failures,
+			LastError:           b.lastError,
+			LastFailure:         formatTime(b.lastFailure),
+			LastSuccess:         formatTime(b.lastSuccess),
+		}
+		if !b.openUntil.IsZero() {
+			status.State = "half_open"
+			if now.Before(b.openUntil) {
+				status.State = "open"
+				status.OpenUntil = formatTime(b.openUntil)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Target < statuses[j].Target })
+	return statuses
+}
+
+// formatTime 格式化时间，零值返回空字符串
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,177 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/parser"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/snapshot"
+
+	"go.uber.org/zap"
+)
+
+// contentValidator 校验下载内容，返回错误时不覆盖缓存文件
+type contentValidator func(data []byte) error
+
+// PreviousFilePath 缓存文件上一个有效版本的路径
+func PreviousFilePath(path string) string {
+	return path + ".prev"
+}
+
+// cacheMeta 上游响应的缓存校验信息，保存在缓存文件旁，用于条件请求
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// metaFilePath 缓存文件校验信息的路径
+func metaFilePath(path string) string {
+	return path + ".meta"
+}
+
+// loadMeta 读取缓存文件的校验信息；缓存文件不存在或上游地址已变更时返回空值
+func loadMeta(path, url string) cacheMeta {
+	if _, err := os.Stat(path); err != nil {
+		return cacheMeta{}
+	}
+	data, err := os.ReadFile(metaFilePath(path))
+	if err != nil {
+		return cacheMeta{}
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL != url {
+		return cacheMeta{}
+	}
+	return meta
+}
+
+// saveMeta 保存缓存文件的校验信息，上游未返回 ETag / Last-Modified 时删除旧的校验信息
+func saveMeta(path string, meta cacheMeta) {
+	if meta.ETag == "" && meta.LastModified == "" {
+		os.Remove(metaFilePath(path))
+		return
+	}
+	data, _ := json.Marshal(meta)
+	if err := writeFileAtomic(metaFilePath(path), data); err != nil {
+		logger.Warn("Failed to save cache meta",
+			zap.String("file", path),
+			zap.Error(err),
+		)
+	}
+}
+
+// subscriptionValidator 校验订阅内容可解析且节点数不低于 min_nodes
+func subscriptionValidator(sub global.SubscriptionConfig) contentValidator {
+	return func(data []byte) error {
+		result, err := parser.Parse(data)
+		if err != nil {
+			return fmt.Errorf("invalid subscription content: %w", err)
+		}
+		if minNodes := sub.GetMinNodes(); len(result.Outbounds) < minNodes {
+			return fmt.Errorf("too few nodes: got %d, want at least %d", len(result.Outbounds), minNodes)
+		}
+		return nil
+	}
+}
+
+// templateValidator 校验模板内容，拒绝上游返回的 HTML 错误页；结构化模板须为合法 JSON
+func templateValidator(structured bool) contentValidator {
+	return func(data []byte) error {
+		if looksLikeHTML(data) {
+			return fmt.Errorf("invalid template content: received html page")
+		}
+		if structured && !json.Valid(data) {
+			return fmt.Errorf("invalid template content: not valid json")
+		}
+		return nil
+	}
+}
+
+// looksLikeHTML 判断内容是否为 HTML 页面
+func looksLikeHTML(data []byte) bool {
+	head := bytes.ToLower(bytes.TrimSpace(data))
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+// commitFile 原子写入缓存文件，内容变化时将旧文件保留为上一个有效版本
+func commitFile(path string, data []byte) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("create cache dir error: %w", err)
+	}
+
+	old, err := os.ReadFile(path)
+	if err == nil {
+		if bytes.Equal(old, data) {
+			return false, nil
+		}
+		if err := writeFileAtomic(PreviousFilePath(path), old); err != nil {
+			logger.Warn("Failed to keep previous cache file",
+				zap.String("file", path),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return false, fmt.Errorf("write cache file error: %w", err)
+	}
+	return true, nil
+}
+
+// RestoreSnapshot 将缓存文件回滚到指定快照，并固定该文件以免自动更新重新下载上游内容覆盖回滚结果
+func RestoreSnapshot(target snapshot.Target, id string) error {
+	data, err := snapshot.Read(target.Path, id)
+	if err != nil {
+		return err
+	}
+	if _, err := commitFile(target.Path, data); err != nil {
+		return err
+	}
+	// 回滚后的内容与上游不一致，解除固定后需完整下载
+	os.Remove(metaFilePath(target.Path))
+	if err := savePin(target.Path, Pin{Snapshot: id, PinnedAt: time.Now()}); err != nil {
+		return fmt.Errorf("pin cache file error: %w", err)
+	}
+
+	logger.Info("Restored cache file from snapshot",
+		zap.String("kind", target.Kind),
+		zap.String("name", target.Name),
+		zap.String("snapshot", id),
+	)
+	return nil
+}
+
+// writeFileAtomic 先写入同目录临时文件再重命名，避免读取到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
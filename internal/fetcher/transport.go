@@ -0,0 +1,99 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+
+	"go.uber.org/zap"
+)
+
+var (
+	clientMutex sync.Mutex
+	// clients 按代理与 TLS 设置复用的 HTTP 客户端，相同设置的上游共享连接池
+	clients = make(map[global.Transport]*http.Client)
+)
+
+// resetClients 关闭并清空已创建的客户端，配置重载后按新设置重新创建
+func resetClients() {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	for _, client := range clients {
+		client.CloseIdleConnections()
+	}
+	clients = make(map[global.Transport]*http.Client)
+}
+
+// clientFor 获取指定代理与 TLS 设置对应的 HTTP 客户端
+func clientFor(t global.Transport) (*http.Client, error) {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	if client, ok := clients[t]; ok {
+		return client, nil
+	}
+	client, err := newClient(t)
+	if err != nil {
+		return nil, err
+	}
+	clients[t] = client
+	return client, nil
+}
+
+// newClient 创建 HTTP 客户端，超时由每个请求的 context 控制，以支持订阅源独立的超时设置
+func newClient(t global.Transport) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file error: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s contains no valid certificates", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxyURL, err := t.GetProxyURL()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+		logger.Info("Using upstream proxy", zap.String("proxy", proxyURL.Redacted()))
+	}
+	if t.InsecureSkipVerify {
+		logger.Warn("TLS certificate verification disabled for upstream fetches")
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
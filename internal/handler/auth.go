@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/signer"
+
+	"go.uber.org/zap"
+)
+
+var (
+	errUnauthorized = errors.New("invalid password or token")
+	errTokenExpired = errors.New("token expired")
+)
+
+// principal 请求方身份：管理员（password）、订阅用户（token）或签名链接（sign）
+type principal struct {
+	admin    bool
+	user     global.UserConfig
+	limitKey string // 按 token / 签名链接限流的键
+}
+
+// credentialKey 凭据的缓存键，不保存凭据原文
+func credentialKey(kind, secret string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(kind + "\x00" + secret))
+}
+
+// authenticate 校验请求凭据
+// password 为管理员密码，拥有全部权限；token 为用户 token，受模板与节点权限限制
+func (h *Handler) authenticate(r *http.Request) (principal, error) {
+	query := r.URL.Query()
+	if password := query.Get("password"); password != "" {
+		key := credentialKey("password", password)
+		if _, ok := h.authCache.Load(key); ok || h.cfg.CheckAdminPassword(password) {
+			h.authCache.Store(key, "")
+			return principal{admin: true}, nil
+		}
+	}
+
+	// 签名链接：只校验签名与有效期，权限限定为签名绑定的模板与节点过滤
+	if sign := query.Get("sign"); sign != "" {
+		claims, err := signer.Verify(h.cfg.Auth.SigningKey, sign, time.Now())
+		if errors.Is(err, signer.ErrExpired) {
+			return principal{}, errTokenExpired
+		}
+		if err != nil {
+			return principal{}, errUnauthorized
+		}
+		key := credentialKey("sign", sign)
+		return principal{
+			user: global.UserConfig{
+				Name:      fmt.Sprintf("signed(%s)", claims.Filter),
+				Templates: []string{claims.Template},
+				Filter:    claims.Filter,
+			},
+			limitKey: "sign:" + string(key[:]),
+		}, nil
+	}
+
+	if token := query.Get("token"); token != "" {
+		key := credentialKey("token", token)
+		var user global.UserConfig
+		var ok bool
+		if name, cached := h.authCache.Load(key); cached {
+			// 重新按名称读取，确保禁用、过期等配置即时生效
+			user, ok = h.cfg.GetUser(name.(string))
+		} else {
+			user, ok = h.cfg.GetUserByToken(token)
+		}
+		if !ok {
+			h.authCache.Delete(key)
+			return principal{}, errUnauthorized
+		}
+		h.authCache.Store(key, user.Name)
+		if user.IsExpired(time.Now()) {
+			return principal{}, errTokenExpired
+		}
+		return principal{user: user, limitKey: "user:" + user.Name}, nil
+	}
+
+	return principal{}, errUnauthorized
+}
+
+// authorize 在鉴权前后执行访问防护：被锁定或超出频率限制的客户端返回 429，
+// 凭据无效时累计失败次数并返回 401
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) (principal, bool) {
+	ip := h.clientIP(r)
+	if wait, locked := h.lockedOut(ip); locked {
+		h.writeTooManyRequests(w, r, ip, wait, "locked out")
+		return principal{}, false
+	}
+	if wait, ok := h.allowRequest("ip:"+ip, h.cfg.Security.ClientRate); !ok {
+		h.writeTooManyRequests(w, r, ip, wait, "client rate limit")
+		return principal{}, false
+	}
+
+	p, err := h.authenticate(r)
+	if err != nil {
+		if errors.Is(err, errUnauthorized) {
+			h.recordAuthFailure(ip)
+		}
+		h.writeUnauthorized(w, r, err)
+		return principal{}, false
+	}
+
+	if p.admin {
+		h.clearAuthFailures(ip)
+	} else if wait, ok := h.allowRequest(p.limitKey, h.cfg.Security.TokenRate); !ok {
+		h.writeTooManyRequests(w, r, ip, wait, "token rate limit")
+		return principal{}, false
+	}
+	return p, true
+}
+
+// requireAdmin 校验管理员密码，失败时写入 401 响应
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	p, ok := h.authorize(w, r)
+	if !ok {
+		return false
+	}
+	if !p.admin {
+		h.writeUnauthorized(w, r, errUnauthorized)
+		return false
+	}
+	return true
+}
+
+// writeUnauthorized 输出鉴权失败响应
+func (h *Handler) writeUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	message := "Password Error"
+	if errors.Is(err, errTokenExpired) {
+		message = "Token Expired"
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(message))
+	h.logger.Warn("Unauthorized request",
+		zap.String("remote_addr", h.clientIP(r)),
+		zap.String("path", r.URL.Path),
+		zap.Error(err),
+	)
+}
+
+// name 用于日志与缓存键的身份名称
+func (p principal) name() string {
+	if p.admin {
+		return "admin"
+	}
+	return p.user.Name
+}
+
+// canUseTemplate 是否允许使用指定模板
+func (p principal) canUseTemplate(templateName string) bool {
+	return p.admin || p.user.CanUseTemplate(templateName)
+}
+
+// defaultTemplate 请求未指定模板时使用的模板：用户无权使用默认模板时使用其允许的第一个模板
+func (p principal) defaultTemplate(defaultTemplate string) string {
+	if p.canUseTemplate(defaultTemplate) || len(p.user.Templates) == 0 {
+		return defaultTemplate
+	}
+	return p.user.Templates[0]
+}
+
+// nodeFilter 用户的节点过滤关键词，为空表示不限制
+func (p principal) nodeFilter() string {
+	if p.admin {
+		return ""
+	}
+	return p.user.Filter
+}
+
+// userHidden 返回 names 中该用户无权看到的名称，过滤表达式无效时隐藏全部名称
+func (s *Snapshot) userHidden(p principal, names []string) map[string]bool {
+	filter := p.nodeFilter()
+	if filter == "" {
+		return nil
+	}
+
+	matched, err := s.matchNames(names, filter)
+	if err != nil {
+		s.logger.Warn("Invalid user filter expression", zap.String("user", p.name()), zap.Error(err))
+	}
+	allowed := make(map[string]bool)
+	for _, name := range matched {
+		allowed[name] = true
+	}
+	hidden := make(map[string]bool)
+	for _, name := range names {
+		if !allowed[name] {
+			hidden[name] = true
+		}
+	}
+	return hidden
+}
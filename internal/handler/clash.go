@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/exporter"
+
+	"github.com/flosch/pongo2/v6"
+	"go.uber.org/zap"
+)
+
+// rebuildClashProxies 根据节点数据生成 Clash 节点，仅在 Snapshot 发布前调用
+func (s *Snapshot) rebuildClashProxies() {
+	proxies, warnings := exporter.ToClashProxies(s.nodesData)
+	for _, warning := range warnings {
+		s.logger.Debug("Node skipped for clash output",
+			zap.String("reason", warning),
+		)
+	}
+
+	names := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		if name, ok := proxy["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+
+	s.clashProxies = proxies
+	s.clashNames = names
+}
+
+// serveClash 输出 Clash.Meta YAML 配置
+// 模板配置了 clash_url 时渲染 Clash 模板，否则使用内置的默认配置
+func (h *Handler) serveClash(w http.ResponseWriter, r *http.Request, p principal, snap *Snapshot, templateName, actualTemplateName, noNodeName, setType string) {
+	currentTemplate := snap.clashTemplates[templateName]
+	proxies := snap.clashProxies
+	hidden := snap.hiddenNames(p, templateName, snap.clashNames)
+
+	key := newRenderKey(p, templateName, "clash", setType)
+	if entry, ok := snap.renders.get(key); ok {
+		h.writeRender(w, r, entry)
+		h.logger.Info("Served cached clash config",
+			zap.String("remote_addr", h.clientIP(r)),
+			zap.String("user", p.name()),
+			zap.String("template", templateName),
+			zap.String("type", setType),
+		)
+		return
+	}
+
+	tplConfig, _ := h.cfg.GetTemplate(templateName)
+
+	var output []byte
+	if tplConfig.ClashURL != "" {
+		if currentTemplate == nil {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("Clash template '%s' not loaded", templateName)))
+			return
+		}
+
+		// 构建模板上下文
+		context := pongo2.Context{
+			"Proxies":   pongo2.AsSafeValue(exporter.ClashProxiesFlow(proxies)),
+			"setType":   setType,
+			"nodeCount": len(proxies),
+			"noNode":    noNodeName,
+		}
+
+		rendered, err := currentTemplate.ExecuteBytes(context)
+		if err != nil {
+			h.logger.Error("Error rendering clash template",
+				zap.Error(err),
+				zap.String("template", templateName),
+			)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("Server Error: %v", err)))
+			return
+		}
+		output = []byte(snap.expandNames(string(rendered)))
+	} else {
+		rendered, err := exporter.DefaultClashConfig(proxies)
+		if err != nil {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("Server Error: %v", err)))
+			return
+		}
+		output = rendered
+	}
+
+	// 按用户的节点权限移除无权访问的节点
+	output, err := pruneClash(output, hidden, noNodeName)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("Server Error: %v", err)))
+		return
+	}
+
+	// hidden 可能包含没有 Clash 形式的节点，按实际保留的节点计数
+	visible := 0
+	for _, proxy := range proxies {
+		if name, _ := proxy["name"].(string); !hidden[name] {
+			visible++
+		}
+	}
+
+	entry := newRenderEntry(output, "text/yaml; charset=utf-8", visible)
+	snap.renders.put(key, entry)
+	h.writeRender(w, r, entry)
+
+	h.logger.Info("Successfully served clash config",
+		zap.String("remote_addr", h.clientIP(r)),
+		zap.String("user", p.name()),
+		zap.String("template", templateName),
+		zap.String("template_name", actualTemplateName),
+		zap.String("type", setType),
+		zap.Int("proxy_count", visible),
+	)
+}
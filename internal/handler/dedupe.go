@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/rename"
+
+	"go.uber.org/zap"
+)
+
+// dedupeNodes 按配置的策略合并重复节点，保留先出现的节点；合并后仍同名的节点追加 " #2" 等后缀
+// detour 引用同步更新：引用被合并节点时改为引用保留的节点，引用被追加后缀的节点时改为新名称
+func (h *Handler) dedupeNodes(pool []rename.Node) []rename.Node {
+	strategy := h.cfg.Dedupe.GetStrategy()
+
+	kept := make([]rename.Node, 0, len(pool))
+	seen := make(map[string]rename.Node)              // 节点标识 -> 保留的节点
+	merged := make(map[string]map[string]rename.Node) // 订阅源 -> 被合并的 tag -> 保留的节点
+	for _, node := range pool {
+		key := identityKey(node.Outbound, strategy)
+		if key == "" {
+			kept = append(kept, node)
+			continue
+		}
+
+		first, ok := seen[key]
+		if !ok {
+			seen[key] = node
+			kept = append(kept, node)
+			continue
+		}
+
+		tag, _ := node.Outbound["tag"].(string)
+		keptTag, _ := first.Outbound["tag"].(string)
+		if merged[node.Source] == nil {
+			merged[node.Source] = make(map[string]rename.Node)
+		}
+		merged[node.Source][tag] = first
+		h.logger.Info("Merged duplicate node",
+			zap.String("strategy", strategy),
+			zap.String("node", tag),
+			zap.String("subscription", node.Source),
+			zap.String("kept", keptTag),
+			zap.String("kept_subscription", first.Source),
+		)
+	}
+
+	renamed := h.suffixDuplicateTags(kept)
+
+	// 保留的节点可能在追加后缀时改名，按改名后的 tag 更新引用
+	for _, node := range kept {
+		detour, ok := node.Outbound["detour"].(string)
+		if !ok {
+			continue
+		}
+		if target, ok := merged[node.Source][detour]; ok {
+			node.Outbound["detour"] = target.Outbound["tag"]
+		} else if tag, ok := renamed[node.Source][detour]; ok {
+			node.Outbound["detour"] = tag
+		}
+	}
+	return kept
+}
+
+// identityKey 计算节点在指定策略下的标识，返回空字符串表示不参与合并
+func identityKey(node map[string]interface{}, strategy string) string {
+	switch strategy {
+	case global.DedupeEndpoint:
+		server, _ := node["server"].(string)
+		if server == "" {
+			return ""
+		}
+		outboundType, _ := node["type"].(string)
+		return fmt.Sprintf("%s|%s|%v", outboundType, strings.ToLower(server), node["server_port"])
+	case global.DedupeCredential:
+		// map 序列化时键有序，除 tag 外的字段完全一致时哈希相同
+		rest := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			if k != "tag" {
+				rest[k] = v
+			}
+		}
+		data, err := json.Marshal(rest)
+		if err != nil {
+			return ""
+		}
+		sum := sha256.Sum256(data)
+		return string(sum[:])
+	}
+	return ""
+}
+
+// suffixDuplicateTags 为重复的 tag 追加 " #2"、" #3" 等后缀，保证节点名称唯一；
+// 返回各订阅源中原 tag 对应的最终 tag（同一订阅源中同名的节点以第一个为准），用于更新 detour 引用
+func (h *Handler) suffixDuplicateTags(nodes []rename.Node) map[string]map[string]string {
+	final := make(map[string]map[string]string)
+	resolve := func(node rename.Node, tag, next string) {
+		if final[node.Source] == nil {
+			final[node.Source] = make(map[string]string)
+		}
+		if _, ok := final[node.Source][tag]; !ok {
+			final[node.Source][tag] = next
+		}
+	}
+
+	used := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if tag, ok := node.Outbound["tag"].(string); ok {
+			used[tag] = true
+		}
+	}
+
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		tag, ok := node.Outbound["tag"].(string)
+		if !ok {
+			continue
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			resolve(node, tag, tag)
+			continue
+		}
+
+		next := tag
+		for n := 2; used[next]; n++ {
+			next = fmt.Sprintf("%s #%d", tag, n)
+		}
+		used[next] = true
+		seen[next] = true
+		node.Outbound["tag"] = next
+		resolve(node, tag, next)
+		h.logger.Info("Renamed duplicate tag",
+			zap.String("node", tag),
+			zap.String("subscription", node.Source),
+			zap.String("renamed", next),
+		)
+	}
+	return final
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/rename"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// dedupeNode 测试用节点：订阅源、tag、服务器与 detour
+type dedupeNode struct {
+	source, tag, server, detour string
+}
+
+func (n dedupeNode) node() rename.Node {
+	outbound := map[string]interface{}{"type": "shadowsocks", "tag": n.tag, "server_port": 443}
+	if n.server != "" {
+		outbound["server"] = n.server
+	}
+	if n.detour != "" {
+		outbound["detour"] = n.detour
+	}
+	return rename.Node{Source: n.source, Outbound: outbound}
+}
+
+func TestDedupeNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		pool     []dedupeNode
+		want     []dedupeNode
+		merged   []string // 合并日志：订阅源/节点 -> 保留的订阅源/节点
+		renamed  []string // 追加后缀日志：订阅源/节点 -> 新名称
+	}{
+		{
+			name:     "detour to merged node",
+			strategy: global.DedupeEndpoint,
+			pool: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"b", "hk-b", "1.1.1.1", ""},
+				{"b", "relay", "2.2.2.2", "hk-b"},
+			},
+			want: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"b", "relay", "2.2.2.2", "hk"},
+			},
+			merged: []string{"b/hk-b -> a/hk"},
+		},
+		{
+			name:     "detour to suffixed tag",
+			strategy: global.DedupeTag,
+			pool: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"a", "relay", "3.3.3.3", "hk"},
+				{"b", "hk", "2.2.2.2", ""},
+				{"b", "relay", "4.4.4.4", "hk"},
+			},
+			want: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"a", "relay", "3.3.3.3", "hk"},
+				{"b", "hk #2", "2.2.2.2", ""},
+				{"b", "relay #2", "4.4.4.4", "hk #2"},
+			},
+			renamed: []string{"b/hk -> hk #2", "b/relay -> relay #2"},
+		},
+		{
+			name:     "detour to merged node that gets a suffix",
+			strategy: global.DedupeEndpoint,
+			pool: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"b", "hk", "2.2.2.2", ""},
+				{"c", "hk-c", "2.2.2.2", ""},
+				{"c", "relay", "3.3.3.3", "hk-c"},
+			},
+			want: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"b", "hk #2", "2.2.2.2", ""},
+				{"c", "relay", "3.3.3.3", "hk #2"},
+			},
+			merged:  []string{"c/hk-c -> b/hk"},
+			renamed: []string{"b/hk -> hk #2"},
+		},
+		{
+			name:     "collision with an existing suffix across sources",
+			strategy: global.DedupeTag,
+			pool: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"a", "hk #2", "2.2.2.2", ""},
+				{"b", "hk", "3.3.3.3", ""},
+				{"c", "hk", "4.4.4.4", ""},
+			},
+			want: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"a", "hk #2", "2.2.2.2", ""},
+				{"b", "hk #3", "3.3.3.3", ""},
+				{"c", "hk #4", "4.4.4.4", ""},
+			},
+			renamed: []string{"b/hk -> hk #3", "c/hk -> hk #4"},
+		},
+		{
+			name:     "nodes without server are not merged",
+			strategy: global.DedupeEndpoint,
+			pool: []dedupeNode{
+				{"a", "direct", "", ""},
+				{"b", "direct", "", ""},
+			},
+			want: []dedupeNode{
+				{"a", "direct", "", ""},
+				{"b", "direct #2", "", ""},
+			},
+			renamed: []string{"b/direct -> direct #2"},
+		},
+		{
+			name:     "credential ignores tag",
+			strategy: global.DedupeCredential,
+			pool: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"b", "香港", "1.1.1.1", ""},
+				{"b", "jp", "2.2.2.2", ""},
+			},
+			want: []dedupeNode{
+				{"a", "hk", "1.1.1.1", ""},
+				{"b", "jp", "2.2.2.2", ""},
+			},
+			merged: []string{"b/香港 -> a/hk"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			h := &Handler{
+				cfg:    &global.Config{Dedupe: global.DedupeConfig{Strategy: tt.strategy}},
+				logger: zap.New(core),
+			}
+
+			pool := make([]rename.Node, 0, len(tt.pool))
+			for _, n := range tt.pool {
+				pool = append(pool, n.node())
+			}
+
+			var got []dedupeNode
+			for _, node := range h.dedupeNodes(pool) {
+				tag, _ := node.Outbound["tag"].(string)
+				server, _ := node.Outbound["server"].(string)
+				detour, _ := node.Outbound["detour"].(string)
+				got = append(got, dedupeNode{node.Source, tag, server, detour})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nodes = %v, want %v", got, tt.want)
+			}
+
+			var merged, renamed []string
+			for _, entry := range logs.All() {
+				fields := entry.ContextMap()
+				switch entry.Message {
+				case "Merged duplicate node":
+					merged = append(merged, fmt.Sprintf("%s/%s -> %s/%s",
+						fields["subscription"], fields["node"], fields["kept_subscription"], fields["kept"]))
+					if fields["strategy"] != tt.strategy {
+						t.Errorf("merge logged strategy %v, want %s", fields["strategy"], tt.strategy)
+					}
+				case "Renamed duplicate tag":
+					renamed = append(renamed, fmt.Sprintf("%s/%s -> %s",
+						fields["subscription"], fields["node"], fields["renamed"]))
+				}
+			}
+			if !reflect.DeepEqual(merged, tt.merged) {
+				t.Errorf("merge logs = %v, want %v", merged, tt.merged)
+			}
+			if !reflect.DeepEqual(renamed, tt.renamed) {
+				t.Errorf("rename logs = %v, want %v", renamed, tt.renamed)
+			}
+		})
+	}
+}
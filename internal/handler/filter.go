@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodefilter"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/rename"
+)
+
+// compiledFilters 加载配置时编译的全局与各模板过滤规则
+type compiledFilters struct {
+	global    *nodefilter.Filter
+	templates map[string]*nodefilter.Filter
+}
+
+// compileFilters 编译配置中的全局、模板过滤规则，并校验用户的筛选表达式；
+// 表达式错误在加载配置时报告，而不是在渲染时把节点全部隐藏
+func compileFilters(c *global.Config) (compiledFilters, error) {
+	filters := compiledFilters{templates: make(map[string]*nodefilter.Filter)}
+
+	filter, err := nodefilter.Compile(c.NodeFilter)
+	if err != nil {
+		return filters, fmt.Errorf("node_filter %w", err)
+	}
+	filters.global = filter
+
+	for name, tpl := range c.Templates {
+		if tpl.NodeFilter.IsEmpty() {
+			continue
+		}
+		filter, err := nodefilter.Compile(tpl.NodeFilter)
+		if err != nil {
+			return filters, fmt.Errorf("template '%s' node_filter %w", name, err)
+		}
+		filters.templates[name] = filter
+	}
+
+	for _, user := range c.Users {
+		if err := nodeexpr.Validate(user.Filter); err != nil {
+			return filters, fmt.Errorf("user '%s' filter: %w", user.Name, err)
+		}
+	}
+	return filters, nil
+}
+
+// droppedNode 被过滤规则移除的节点
+type droppedNode struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// filterResult 一组过滤规则的执行结果
+type filterResult struct {
+	Kept    int           `json:"kept"`
+	Dropped []droppedNode `json:"dropped"`
+}
+
+// filterReport 全局与各模板过滤规则的执行结果，用于调试
+type filterReport struct {
+	Global    filterResult            `json:"global"`
+	Templates map[string]filterResult `json:"templates"`
+}
+
+// applyGlobalFilter 按全局 node_filter 从节点池中移除节点
+func (h *Handler) applyGlobalFilter(pool []rename.Node) ([]rename.Node, filterResult) {
+	result := filterResult{Dropped: []droppedNode{}}
+	filter := h.filters.global
+
+	// 记录被移除的节点（订阅源 + tag），detour 只引用同一订阅源中的节点
+	dropped := make(map[[2]string]bool)
+	kept := pool[:0]
+	for _, node := range pool {
+		if ok, reason := filter.Match(node.Outbound, node.Source); !ok {
+			name, _ := node.Outbound["tag"].(string)
+			dropped[[2]string{node.Source, name}] = true
+			result.Dropped = append(result.Dropped, droppedNode{Name: name, Source: node.Source, Reason: reason})
+			continue
+		}
+		kept = append(kept, node)
+	}
+
+	// 通过 detour 依赖被移除节点的节点一并移除，避免输出悬空引用，依赖可能是多级的
+	for changed := true; changed; {
+		changed = false
+		remaining := kept[:0]
+		for _, node := range kept {
+			detour, _ := node.Outbound["detour"].(string)
+			if detour != "" && dropped[[2]string{node.Source, detour}] {
+				name, _ := node.Outbound["tag"].(string)
+				dropped[[2]string{node.Source, name}] = true
+				result.Dropped = append(result.Dropped, droppedNode{
+					Name:   name,
+					Source: node.Source,
+					Reason: fmt.Sprintf("detour to dropped node %q", detour),
+				})
+				changed = true
+				continue
+			}
+			remaining = append(remaining, node)
+		}
+		kept = remaining
+	}
+	result.Kept = len(kept)
+	return kept, result
+}
+
+// buildTemplateFilters 计算各模板 node_filter 需要隐藏的节点，渲染时与用户权限一并移除
+func (h *Handler) buildTemplateFilters(nodes []map[string]interface{}, sources map[string]string) (map[string]map[string]bool, map[string]filterResult) {
+	hidden := make(map[string]map[string]bool)
+	results := make(map[string]filterResult)
+	for name, filter := range h.filters.templates {
+		result := filterResult{Dropped: []droppedNode{}}
+		names := make(map[string]bool)
+		for _, node := range nodes {
+			tag, _ := node["tag"].(string)
+			if ok, reason := filter.Match(node, sources[tag]); !ok {
+				names[tag] = true
+				result.Dropped = append(result.Dropped, droppedNode{Name: tag, Reason: reason})
+				continue
+			}
+			result.Kept++
+		}
+		hidden[name] = names
+		results[name] = result
+	}
+	return hidden, results
+}
+
+// hiddenNames 合并模板过滤规则与用户权限，返回本次渲染需要移除的节点名称；
+// 通过 detour 依赖被移除节点的节点一并移除
+func (s *Snapshot) hiddenNames(p principal, templateName string, names []string) map[string]bool {
+	hidden := s.userHidden(p, names)
+	templateHidden := s.templateHidden[templateName]
+	if len(hidden)+len(templateHidden) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]bool, len(hidden)+len(templateHidden))
+	for name := range hidden {
+		merged[name] = true
+	}
+	for name := range templateHidden {
+		merged[name] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, node := range s.nodesData {
+			tag, _ := node["tag"].(string)
+			detour, _ := node["detour"].(string)
+			if detour != "" && merged[detour] && !merged[tag] {
+				merged[tag] = true
+				changed = true
+			}
+		}
+	}
+	return merged
+}
+
+// HandleFilters 节点过滤调试接口，列出全局与各模板过滤规则移除的节点及命中的规则
+func (h *Handler) HandleFilters(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	snap := h.loadSnapshot()
+	writeJSON(w, map[string]interface{}{
+		"status":    "success",
+		"global":    snap.filters.Global,
+		"templates": snap.filters.Templates,
+	})
+}
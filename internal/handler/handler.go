@@ -5,149 +5,436 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/haierkeys/singbox-subscribe-convert/global"
 	"github.com/haierkeys/singbox-subscribe-convert/internal/fetcher"
-	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodefilter"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/parser"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/region"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/rename"
 
 	"github.com/flosch/pongo2/v6"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
-var (
-	cfg       *global.Config
-	logger    *zap.Logger
-	nodesName []string
-	nodesData []map[string]interface{}
-	nodes     []string
-	templates map[string]*pongo2.Template
-	dataMutex sync.RWMutex
-)
-
-// NodeFile 节点文件结构
-type NodeFile struct {
-	Outbounds []map[string]interface{} `json:"outbounds"`
+// Handler 订阅转换服务，持有配置、当前 Snapshot 与鉴权、限流等运行状态；
+// HTTP 接口与重载函数均为其方法，多个 Handler 之间互不影响
+type Handler struct {
+	cfg    *global.Config
+	logger *zap.Logger
+
+	// current 当前对外提供服务的 Snapshot
+	current atomic.Pointer[Snapshot]
+	// reloadMutex 串行化重载，避免并发重载时互相覆盖
+	reloadMutex sync.Mutex
+	// refreshGroup 合并并发的全量刷新请求
+	refreshGroup singleflight.Group
+	// refreshMutex 全量刷新期间持有，文件监控触发的重载等待刷新完成
+	refreshMutex sync.Mutex
+
+	// filters 当前配置编译后的过滤规则
+	filters compiledFilters
+	// trustedProxies 解析后的可信代理网段，避免每次请求重复解析
+	trustedProxies []*net.IPNet
+
+	// authCache 缓存校验通过的凭据（键为凭据摘要，值为用户名，管理员为空字符串），
+	// 避免每次请求都进行 bcrypt 计算
+	authCache sync.Map
+	// lastGoodRenders 用户 + 模板名 + setType -> 上次通过校验的渲染结果
+	lastGoodRenders *lruCache[string, string]
+
+	limitMutex   sync.Mutex
+	authFailures map[string]*authFailure
+	rateBuckets  map[string]*rateBucket
+	lastSweep    time.Time
 }
 
-// Init 初始化 handler
-func Init(c *global.Config, l *zap.Logger) error {
-	cfg = c
-	logger = l
+// New 创建 Handler 并加载缓存中的节点与模板，过滤规则等配置错误在此时返回
+func New(c *global.Config, l *zap.Logger) (*Handler, error) {
+	filters, err := compileFilters(c)
+	if err != nil {
+		return nil, err
+	}
 
-	// 初始化模板映射
-	templates = make(map[string]*pongo2.Template)
+	h := &Handler{
+		cfg:             c,
+		logger:          l,
+		filters:         filters,
+		lastGoodRenders: newLRUCache[string, string](maxLastGood),
+		authFailures:    make(map[string]*authFailure),
+		rateBuckets:     make(map[string]*rateBucket),
+	}
+	h.current.Store(h.newSnapshot())
 
-	// 注册自定义过滤器
-	pongo2.RegisterFilter("NotesName", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	// 可信代理已在配置校验时检查格式
+	h.trustedProxies, _ = c.GetTrustedProxies()
 
-		paramStr := ""
-		if in != nil {
-			paramStr = in.String()
-		}
-		result := nodeNameFilter(paramStr)
-		return pongo2.AsSafeValue(result), nil
-	})
+	// 名称无法识别地区时按服务器 IP 查询 GeoIP 数据库
+	if err := region.OpenDatabase(h.cfg.Region.GeoIPDatabase); err != nil {
+		h.logger.Warn("Failed to open geoip database, region detection uses node names only",
+			zap.String("path", h.cfg.Region.GeoIPDatabase),
+			zap.Error(err),
+		)
+	}
 
-	if err := ReloadData(); err != nil {
-		logger.Warn("Failed to load initial data",
+	registerFilters()
+
+	if err := h.ReloadData(); err != nil {
+		h.logger.Warn("Failed to load initial data",
 			zap.Error(err),
 		)
 	}
 
-	if err := ReloadAllTemplates(); err != nil {
-		logger.Warn("Failed to load initial templates",
+	if err := h.ReloadAllTemplates(); err != nil {
+		h.logger.Warn("Failed to load initial templates",
 			zap.Error(err),
 		)
 	}
 
-	return nil
+	return h, nil
+}
+
+// registerFiltersOnce pongo2 过滤器为全局注册，多个 Handler 共享同一组只输出占位符的过滤器
+var registerFiltersOnce sync.Once
+
+// registerFilters 注册自定义模板过滤器，输出占位符，渲染后由请求使用的 Snapshot 展开
+func registerFilters() {
+	registerFiltersOnce.Do(func() {
+		pongo2.RegisterFilter("NotesName", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+
+			paramStr := ""
+			if in != nil {
+				paramStr = in.String()
+			}
+			if err := nodeexpr.Validate(paramStr); err != nil {
+				return nil, &pongo2.Error{Sender: "filter:NotesName", OrigError: err}
+			}
+			return pongo2.AsSafeValue(nameMarker(markerNodes, paramStr)), nil
+		})
+
+		// Clash 模板中使用，仅返回可转换为 Clash 节点的名称
+		pongo2.RegisterFilter("ProxyNames", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+			paramStr := ""
+			if in != nil {
+				paramStr = in.String()
+			}
+			if err := nodeexpr.Validate(paramStr); err != nil {
+				return nil, &pongo2.Error{Sender: "filter:ProxyNames", OrigError: err}
+			}
+			return pongo2.AsSafeValue(nameMarker(markerProxies, paramStr)), nil
+		})
+
+		// 按地区代码筛选节点，如 {{ "HK|TW"|RegionNames }}；ProxyRegionNames 用于 Clash 模板
+		pongo2.RegisterFilter("RegionNames", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+			paramStr := ""
+			if in != nil {
+				paramStr = in.String()
+			}
+			return pongo2.AsSafeValue(nameMarker(markerRegions, paramStr)), nil
+		})
+		pongo2.RegisterFilter("ProxyRegionNames", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+			paramStr := ""
+			if in != nil {
+				paramStr = in.String()
+			}
+			return pongo2.AsSafeValue(nameMarker(markerProxyRegions, paramStr)), nil
+		})
+	})
 }
 
-// ReloadData 重新加载节点数据
-func ReloadData() error {
-	dataMutex.Lock()
-	defer dataMutex.Unlock()
+// SourceStatus 订阅源加载状态
+type SourceStatus struct {
+	Name      string `json:"name"`
+	NodeCount int    `json:"node_count"`
+	Error     string `json:"error,omitempty"`
+}
 
-	nodeFilePath := cfg.GetNodeFilePath()
+// loadSourceNodes 读取单个订阅源的缓存文件并解析为 sing-box outbounds
+func (h *Handler) loadSourceNodes(sub global.SubscriptionConfig) ([]map[string]interface{}, error) {
+	nodeFilePath := h.cfg.GetSubscriptionFilePathByName(sub.Name)
 
 	if _, err := os.Stat(nodeFilePath); os.IsNotExist(err) {
-		return fmt.Errorf("node file not found: %s", nodeFilePath)
+		return nil, fmt.Errorf("node file not found: %s", nodeFilePath)
 	}
 
-	data, err := os.ReadFile(nodeFilePath)
+	var result *parser.Result
+	err := h.loadWithFallback(nodeFilePath, func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read node file error: %w", err)
+		}
+
+		// 自动识别 sing-box JSON / 分享链接等订阅格式
+		result, err = parser.Parse(data)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("read node file error: %w", err)
+		return nil, err
+	}
+
+	for _, warning := range result.Warnings {
+		h.logger.Warn("Skipped invalid node",
+			zap.String("subscription", sub.Name),
+			zap.String("reason", warning),
+		)
+	}
+
+	h.logger.Debug("Parsed subscription",
+		zap.String("subscription", sub.Name),
+		zap.String("format", string(result.Format)),
+		zap.Int("outbounds", len(result.Outbounds)),
+		zap.Int("skipped", len(result.Warnings)),
+	)
+
+	return result.Outbounds, nil
+}
+
+// loadWithFallback 加载缓存文件，失败时回退到 fetcher 保留的上一个有效版本
+func (h *Handler) loadWithFallback(path string, load func(path string) error) error {
+	err := load(path)
+	if err == nil {
+		return nil
+	}
+
+	prevPath := fetcher.PreviousFilePath(path)
+	if _, statErr := os.Stat(prevPath); statErr != nil {
+		return err
+	}
+	if prevErr := load(prevPath); prevErr != nil {
+		return err
 	}
 
-	var nodeFile NodeFile
-	if err := json.Unmarshal(data, &nodeFile); err != nil {
-		return fmt.Errorf("parse node file error: %w", err)
+	h.logger.Warn("Cache file invalid, loaded previous good version",
+		zap.String("file", path),
+		zap.Error(err),
+	)
+	return nil
+}
+
+// ReloadData 重新加载节点数据，将所有启用的订阅源合并为一个节点池
+// 单个订阅源加载失败时仅记录日志，其余订阅源照常提供服务
+func (h *Handler) ReloadData() error {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	next := h.loadSnapshot().clone()
+	newNodesName := []string{}
+	newNodesData := make([]map[string]interface{}, 0)
+	newNodes := []string{}
+	newSourceStatus := make([]SourceStatus, 0)
+
+	var errors []string
+	var pool []rename.Node
+
+	for _, sub := range h.cfg.GetEnabledSubscriptions() {
+		// 先记录文件状态再读取，读取期间文件被替换时文件监控会再次触发重载
+		nodeFilePath := h.cfg.GetSubscriptionFilePathByName(sub.Name)
+		next.files[nodeFilePath] = statFile(nodeFilePath)
+
+		outbounds, err := h.loadSourceNodes(sub)
+		if err != nil {
+			h.logger.Warn("Failed to load subscription",
+				zap.String("subscription", sub.Name),
+				zap.Error(err),
+			)
+			newSourceStatus = append(newSourceStatus, SourceStatus{Name: sub.Name, Error: err.Error()})
+			errors = append(errors, fmt.Sprintf("%s: %v", sub.Name, err))
+			continue
+		}
+
+		newSourceStatus = append(newSourceStatus, SourceStatus{Name: sub.Name})
+		for _, node := range outbounds {
+			pool = append(pool, rename.Node{Source: sub.Name, Outbound: node})
+		}
+	}
+
+	// 按全局过滤规则移除流量、到期提示等无效节点，在重命名之前按原始名称匹配
+	pool, globalResult := h.applyGlobalFilter(pool)
+	for _, dropped := range globalResult.Dropped {
+		h.logger.Debug("Node filtered",
+			zap.String("node", dropped.Name),
+			zap.String("subscription", dropped.Source),
+			zap.String("reason", dropped.Reason),
+		)
+	}
+
+	// 在生成节点名称前按规则重命名，tag 与 detour 引用同步改写；开启 region.flag 时最后添加地区旗帜
+	rules := h.cfg.Rename
+	if h.cfg.Region.Flag {
+		rules = append(rules[:len(rules):len(rules)], global.RenameRule{Type: global.RenameFlag})
+	}
+	pipeline, err := rename.Compile(rules)
+	if err != nil {
+		return err
+	}
+	if renamed := pipeline.Apply(pool); renamed > 0 {
+		h.logger.Info("Renamed nodes", zap.Int("count", renamed))
 	}
 
-	if nodeFile.Outbounds == nil || len(nodeFile.Outbounds) == 0 {
-		return fmt.Errorf("no outbounds found in node file")
+	sourceIndex := make(map[string]int, len(newSourceStatus))
+	for i, status := range newSourceStatus {
+		sourceIndex[status.Name] = i
 	}
 
-	nodesName = []string{}
-	nodesData = make([]map[string]interface{}, 0)
-	nodes = []string{}
+	// 合并重复节点并保证 tag 唯一
+	pool = h.dedupeNodes(pool)
 
 	// 提取所有节点的 tag
-	for _, node := range nodeFile.Outbounds {
-		if tag, ok := node["tag"].(string); ok {
-			if !util.InSlice(nodesName, tag) {
-				nodesName = append(nodesName, tag)
-				nodesData = append(nodesData, node)
-
-				nodeStr, _ := json.Marshal(node)
-				nodes = append(nodes, string(nodeStr))
-			}
+	for _, item := range pool {
+		node := item.Outbound
+		tag, ok := node["tag"].(string)
+		if !ok {
+			continue
+		}
+		newNodesName = append(newNodesName, tag)
+		newNodesData = append(newNodesData, node)
+
+		nodeStr, _ := json.Marshal(node)
+		newNodes = append(newNodes, string(nodeStr))
+		newSourceStatus[sourceIndex[item.Source]].NodeCount++
+	}
+
+	for _, status := range newSourceStatus {
+		if status.Error == "" {
+			h.logger.Info("✓ Loaded subscription",
+				zap.String("subscription", status.Name),
+				zap.Int("outbounds", status.NodeCount),
+			)
 		}
 	}
 
-	logger.Info("✓ Loaded node data",
-		zap.String("file_path", nodeFilePath),
-		zap.Int("outbounds", len(nodesName)),
+	next.sources = newSourceStatus
+
+	if len(newNodesData) == 0 {
+		// 保留原有节点，仅更新订阅源状态
+		h.current.Store(next)
+		return fmt.Errorf("no outbounds loaded from any subscription: %s", strings.Join(errors, "; "))
+	}
+
+	nodeSources := make(map[string]string, len(pool))
+	for _, item := range pool {
+		if tag, ok := item.Outbound["tag"].(string); ok {
+			nodeSources[tag] = item.Source
+		}
+	}
+	templateHidden, templateResults := h.buildTemplateFilters(newNodesData, nodeSources)
+
+	next.nodesName = newNodesName
+	next.nodesData = newNodesData
+	next.templateHidden = templateHidden
+	next.regions = classifyRegions(pool)
+	next.nodeSources = nodeSources
+	next.exprNodes = make(map[string]nodeexpr.Node, len(newNodesData))
+	for _, node := range newNodesData {
+		tag, _ := node["tag"].(string)
+		next.exprNodes[tag] = nodefilter.ExprNode(node, nodeSources[tag], next.regions[tag])
+	}
+	next.filters = filterReport{Global: globalResult, Templates: templateResults}
+	next.nodesJSON = strings.Join(newNodes, ",\r\n")
+	next.rebuildClashProxies()
+	h.current.Store(next)
+
+	h.logger.Info("✓ Loaded node data",
+		zap.Int("subscriptions", len(newSourceStatus)),
+		zap.Int("failed_subscriptions", len(errors)),
+		zap.Int("outbounds", len(newNodesName)),
 	)
 	return nil
 }
 
 // ReloadTemplateByName 根据名称重新加载模板
-func ReloadTemplateByName(templateName string) error {
-	dataMutex.Lock()
-	defer dataMutex.Unlock()
+func (h *Handler) ReloadTemplateByName(templateName string) error {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	next := h.loadSnapshot().clone()
+	if err := h.loadTemplate(next, templateName); err != nil {
+		return err
+	}
+	h.current.Store(next)
+	return nil
+}
 
-	templateFilePath := cfg.GetTemplateFilePathByName(templateName)
+// loadTemplate 将模板及其 Clash 模板加载到尚未发布的 Snapshot
+func (h *Handler) loadTemplate(s *Snapshot, templateName string) error {
+	templateFilePath := h.cfg.GetTemplateFilePathByName(templateName)
 	if _, err := os.Stat(templateFilePath); os.IsNotExist(err) {
 		return fmt.Errorf("template file not found: %s", templateFilePath)
 	}
 
-	tpl, err := pongo2.FromFile(templateFilePath)
+	tplConfig, _ := h.cfg.GetTemplate(templateName)
+	s.files[templateFilePath] = statFile(templateFilePath)
+
+	err := h.loadWithFallback(templateFilePath, func(path string) error {
+		if tplConfig.IsStructured() {
+			// 结构化模板：校验为合法 JSON 后保存原始内容，渲染时再解析
+			data, err := loadStructuredTemplate(path)
+			if err != nil {
+				return err
+			}
+			s.jsonTemplates[templateName] = data
+			return nil
+		}
+
+		tpl, err := pongo2.FromFile(path)
+		if err != nil {
+			return err
+		}
+		s.templates[templateName] = tpl
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("load template error: %w", err)
 	}
 
-	templates[templateName] = tpl
-	logger.Info("✓ Loaded template from cache",
+	// 配置了 clash_url 时同时加载 Clash 模板
+	if tplConfig.ClashURL != "" {
+		clashFilePath := h.cfg.GetClashTemplateFilePathByName(templateName)
+		s.files[clashFilePath] = statFile(clashFilePath)
+		err := h.loadWithFallback(clashFilePath, func(path string) error {
+			clashTpl, err := pongo2.FromFile(path)
+			if err != nil {
+				return err
+			}
+			s.clashTemplates[templateName] = clashTpl
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("load clash template error: %w", err)
+		}
+	}
+
+	h.logger.Info("✓ Loaded template from cache",
 		zap.String("template", templateName),
 		zap.String("file_path", templateFilePath),
 	)
 	return nil
 }
 
-// ReloadAllTemplates 重新加载所有启用的模板
-func ReloadAllTemplates() error {
-	enabledTemplates := cfg.GetEnabledTemplates()
+// ReloadAllTemplates 重新加载所有启用的模板，全部加载后一次性发布
+func (h *Handler) ReloadAllTemplates() error {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	enabledTemplates := h.cfg.GetEnabledTemplates()
 	var errors []string
 
+	next := h.loadSnapshot().clone()
+	defer h.current.Store(next)
+
 	for name := range enabledTemplates {
-		if err := ReloadTemplateByName(name); err != nil {
-			logger.Error("Failed to load template",
+		if err := h.loadTemplate(next, name); err != nil {
+			h.logger.Error("Failed to load template",
 				zap.String("template", name),
 				zap.Error(err),
 			)
@@ -163,132 +450,192 @@ func ReloadAllTemplates() error {
 }
 
 // HandleRequest 处理主请求
-func HandleRequest(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	// 如果路径不是根路径，则直接返回 404，不进入鉴权逻辑，避免干扰日志
 	if r.URL.Path != "/" {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	logger.Info("Request received",
-		zap.String("remote_addr", r.RemoteAddr),
+	h.logger.Info("Request received",
+		zap.String("remote_addr", h.clientIP(r)),
 		zap.String("path", r.URL.Path),
 	)
 	queryParams := r.URL.Query()
 	setType := queryParams.Get("type")
-	password := queryParams.Get("password")
 	templateName := queryParams.Get("template")
 	refresh := queryParams.Get("refresh")
+	format := queryParams.Get("format")
 
-	if password != cfg.Auth.Password {
+	p, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	switch format {
+	case "", "singbox", "clash", "uri", "base64":
+	default:
 		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Password Error"))
-		logger.Warn("Unauthorized request",
-			zap.String("remote_addr", r.RemoteAddr),
-			zap.String("path", r.URL.Path),
-		)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("Unsupported format '%s'", format)))
 		return
 	}
 
-	// 如果设置了 refresh 参数，则先拉取最新数据
-	if refresh == "1" || refresh == "true" {
-		logger.Info("Forced refresh via request parameter", zap.String("remote_addr", r.RemoteAddr))
-		// 1. 拉取节点文件
-		if err := fetcher.FetchNodeFile(); err != nil {
-			logger.Error("Failed to fetch node file during refresh", zap.Error(err))
-		} else {
-			ReloadData()
+	// 如果设置了 refresh 参数，则先拉取最新数据（仅管理员）
+	if (refresh == "1" || refresh == "true") && p.admin {
+		h.logger.Info("Forced refresh via request parameter", zap.String("remote_addr", h.clientIP(r)))
+		if errors := h.refreshAll(); len(errors) > 0 {
+			h.logger.Warn("Refresh via request parameter had errors", zap.Strings("errors", errors))
 		}
-
-		// 2. 拉取所有模板并重新加载
-		fetcher.FetchAllTemplates()
-		ReloadAllTemplates()
 	}
 
 	// 获取要使用的模板
 	if templateName == "" {
-		templateName = cfg.DefaultTemplate
+		templateName = p.defaultTemplate(h.cfg.DefaultTemplate)
 	}
 
-	dataMutex.RLock()
-	var currentTemplate *pongo2.Template
-	var actualTemplateName string
-	var noNodeName string
+	if !p.canUseTemplate(templateName) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf("Template '%s' not allowed", templateName)))
+		h.logger.Warn("Template not allowed for user",
+			zap.String("template", templateName),
+			zap.String("user", p.name()),
+			zap.String("remote_addr", h.clientIP(r)),
+		)
+		return
+	}
 
 	// 检查模板是否启用
-	if tplConfig, exists := cfg.GetTemplate(templateName); exists && tplConfig.Enabled {
-		currentTemplate = templates[templateName]
-		actualTemplateName = tplConfig.Name
-		noNodeName = tplConfig.NoNode
-	} else {
-		dataMutex.RUnlock()
+	tplConfig, exists := h.cfg.GetTemplate(templateName)
+	if !exists || !tplConfig.Enabled {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(fmt.Sprintf("Template '%s' not found or not enabled", templateName)))
-		logger.Warn("Template not found or not enabled",
+		h.logger.Warn("Template not found or not enabled",
 			zap.String("template", templateName),
-			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("remote_addr", h.clientIP(r)),
 		)
 		return
 	}
-	dataMutex.RUnlock()
 
-	if currentTemplate == nil {
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("Template '%s' not loaded", templateName)))
+	if format == "uri" || format == "base64" {
+		// 分享链接不渲染模板，但同样应用该模板的 node_filter
+		h.serveURIList(w, r, p, templateName, format == "base64")
 		return
 	}
 
-	// 构建模板上下文
-	context := pongo2.Context{
-		"Nodes":     pongo2.AsSafeValue(strings.Join(nodes, ",\r\n")),
-		"setType":   setType,
-		"nodeCount": len(nodes),
-		"noNode":    noNodeName,
+	// 本次请求的全部渲染都基于同一个 Snapshot
+	snap := h.loadSnapshot()
+	actualTemplateName := tplConfig.Name
+	noNodeName := tplConfig.NoNode
+
+	if format == "clash" {
+		h.serveClash(w, r, p, snap, templateName, actualTemplateName, noNodeName, setType)
+		return
 	}
 
-	output, err := currentTemplate.Execute(context)
-	if err != nil {
-		logger.Error("Error rendering template",
-			zap.Error(err),
+	key := newRenderKey(p, templateName, "singbox", setType)
+	if entry, ok := snap.renders.get(key); ok {
+		h.writeRender(w, r, entry)
+		h.logger.Info("Served cached config",
+			zap.String("remote_addr", h.clientIP(r)),
+			zap.String("user", p.name()),
 			zap.String("template", templateName),
+			zap.String("type", setType),
 		)
+		return
+	}
+
+	structured := tplConfig.IsStructured()
+	currentTemplate := snap.templates[templateName]
+	jsonTemplate := snap.jsonTemplates[templateName]
+	if (structured && jsonTemplate == nil) || (!structured && currentTemplate == nil) {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("Server Error: %v", err)))
+		w.Write([]byte(fmt.Sprintf("Template '%s' not loaded", templateName)))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Profile-Update-Interval", "6")
-	w.Header().Set("Subscription-Userinfo", fmt.Sprintf("upload=0; download=0; total=%d", len(nodes)))
-	// 添加防缓存 Header
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Set("Pragma", "no-cache")
-	w.Header().Set("Expires", "0")
+	var output string
+	var err error
+	if structured {
+		// 结构化模板：按 $filter 填充节点 tag 并追加节点 outbounds
+		output, err = snap.renderStructured(jsonTemplate, noNodeName)
+	} else {
+		// 构建模板上下文
+		context := pongo2.Context{
+			"Nodes":     pongo2.AsSafeValue(snap.nodesJSON),
+			"setType":   setType,
+			"nodeCount": len(snap.nodesData),
+			"noNode":    noNodeName,
+		}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(output))
+		output, err = currentTemplate.Execute(context)
+		output = snap.expandNames(output)
+	}
+	if err == nil {
+		// 按用户的节点权限移除无权访问的节点
+		output, err = pruneSingbox(output, snap.hiddenNames(p, templateName, snap.nodesName), noNodeName)
+	}
+	if err == nil {
+		// 校验渲染结果，避免向客户端返回无效配置
+		err = h.validateOutput(templateName, output, tplConfig.Version)
+	}
+	if err != nil {
+		h.handleRenderFailure(w, r, p, templateName, setType, len(snap.nodesData), err)
+		return
+	}
+	h.storeLastGood(p, templateName, setType, output)
 
-	logger.Info("Successfully served config",
-		zap.String("remote_addr", r.RemoteAddr),
+	entry := newRenderEntry([]byte(output), "application/json", len(snap.nodesData))
+	snap.renders.put(key, entry)
+	h.writeRender(w, r, entry)
+
+	h.logger.Info("Successfully served config",
+		zap.String("remote_addr", h.clientIP(r)),
+		zap.String("user", p.name()),
 		zap.String("template", templateName),
 		zap.String("template_name", actualTemplateName),
 		zap.String("type", setType),
-		zap.Int("node_count", len(nodes)),
+		zap.Int("node_count", len(snap.nodesData)),
 	)
 }
 
+// setSubscriptionHeaders 设置订阅响应的通用 Header
+func setSubscriptionHeaders(w http.ResponseWriter, contentType string, nodeCount int) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Profile-Update-Interval", "6")
+	w.Header().Set("Subscription-Userinfo", fmt.Sprintf("upload=0; download=0; total=%d", nodeCount))
+	// 允许客户端缓存，但每次使用前需通过 ETag 重新验证
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// writeJSONError 输出 JSON 错误响应
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	data, _ := json.Marshal(map[string]string{"status": "error", "error": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
 // HandleHealth 健康检查
-func HandleHealth(w http.ResponseWriter, r *http.Request) {
-	dataMutex.RLock()
-	hasData := len(nodesData) > 0
-	hasTemplate := len(templates) > 0
-	templateCount := len(templates)
-	nodeCount := len(nodesData)
-	dataMutex.RUnlock()
+func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	snap := h.loadSnapshot()
+	hasData := len(snap.nodesData) > 0
+	templateCount := snap.templateCount()
+	hasTemplate := templateCount > 0
+	nodeCount := len(snap.nodesData)
+	sources, _ := json.Marshal(snap.sources)
+	upstreams, _ := json.Marshal(fetcher.UpstreamStatuses())
 
 	status := "ok"
 	code := http.StatusOK
@@ -299,23 +646,23 @@ func HandleHealth(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	fmt.Fprintf(w, `{"status":"%s","has_data":%t,"has_template":%t,"node_count":%d,"template_count":%d}`,
-		status, hasData, hasTemplate, nodeCount, templateCount)
+	fmt.Fprintf(w, `{"status":"%s","has_data":%t,"has_template":%t,"node_count":%d,"template_count":%d,"subscriptions":%s,"upstreams":%s}`,
+		status, hasData, hasTemplate, nodeCount, templateCount, string(sources), string(upstreams))
 }
 
 // PurgeCloudflareCache 清理 Cloudflare 缓存
-func PurgeCloudflareCache() error {
-	if !cfg.Cloudflare.Enabled {
-		logger.Debug("Cloudflare cache purge is disabled")
+func (h *Handler) PurgeCloudflareCache() error {
+	if !h.cfg.Cloudflare.Enabled {
+		h.logger.Debug("Cloudflare cache purge is disabled")
 		return nil
 	}
 
-	if cfg.Cloudflare.PurgeURL == "" {
+	if h.cfg.Cloudflare.PurgeURL == "" {
 		return fmt.Errorf("cloudflare purge_url is not configured")
 	}
 
-	logger.Info("🧹 Starting Cloudflare cache purge...",
-		zap.String("purge_url", cfg.Cloudflare.PurgeURL),
+	h.logger.Info("🧹 Starting Cloudflare cache purge...",
+		zap.String("purge_url", h.cfg.Cloudflare.PurgeURL),
 	)
 
 	// 构建请求体 - 清理所有缓存
@@ -325,20 +672,20 @@ func PurgeCloudflareCache() error {
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		logger.Error("❌ Failed to marshal Cloudflare request body",
+		h.logger.Error("❌ Failed to marshal Cloudflare request body",
 			zap.Error(err),
 		)
 		return fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	logger.Debug("Cloudflare purge request body",
+	h.logger.Debug("Cloudflare purge request body",
 		zap.String("body", string(jsonData)),
 	)
 
 	// 创建 POST 请求
-	req, err := http.NewRequest("POST", cfg.Cloudflare.PurgeURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", h.cfg.Cloudflare.PurgeURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		logger.Error("❌ Failed to create Cloudflare request",
+		h.logger.Error("❌ Failed to create Cloudflare request",
 			zap.Error(err),
 		)
 		return fmt.Errorf("failed to create request: %w", err)
@@ -348,31 +695,31 @@ func PurgeCloudflareCache() error {
 
 	// 设置认证 Headers
 	// 优先使用 API Token (推荐方式)
-	if cfg.Cloudflare.APIToken != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.Cloudflare.APIToken)
-		logger.Debug("Using Cloudflare API Token authentication")
-	} else if cfg.Cloudflare.APIKey != "" && cfg.Cloudflare.APIEmail != "" {
+	if h.cfg.Cloudflare.APIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.cfg.Cloudflare.APIToken)
+		h.logger.Debug("Using Cloudflare API Token authentication")
+	} else if h.cfg.Cloudflare.APIKey != "" && h.cfg.Cloudflare.APIEmail != "" {
 		// 使用 API Key + Email 方式
-		req.Header.Set("X-Auth-Key", cfg.Cloudflare.APIKey)
-		req.Header.Set("X-Auth-Email", cfg.Cloudflare.APIEmail)
-		logger.Debug("Using Cloudflare API Key + Email authentication")
+		req.Header.Set("X-Auth-Key", h.cfg.Cloudflare.APIKey)
+		req.Header.Set("X-Auth-Email", h.cfg.Cloudflare.APIEmail)
+		h.logger.Debug("Using Cloudflare API Key + Email authentication")
 	} else {
-		logger.Error("❌ No Cloudflare authentication configured")
+		h.logger.Error("❌ No Cloudflare authentication configured")
 		return fmt.Errorf("cloudflare authentication not configured: either api_token or (api_key + api_email) is required")
 	}
 
 	// 发送请求
 	client := &http.Client{
-		Timeout: cfg.GetRequestTimeout(),
+		Timeout: h.cfg.GetRequestTimeout(),
 	}
 
-	logger.Info("📤 Sending purge request to Cloudflare API...")
+	h.logger.Info("📤 Sending purge request to Cloudflare API...")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Error("❌ Failed to send request to Cloudflare",
+		h.logger.Error("❌ Failed to send request to Cloudflare",
 			zap.Error(err),
-			zap.String("url", cfg.Cloudflare.PurgeURL),
+			zap.String("url", h.cfg.Cloudflare.PurgeURL),
 		)
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -381,20 +728,20 @@ func PurgeCloudflareCache() error {
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.Error("❌ Failed to read Cloudflare response",
+		h.logger.Error("❌ Failed to read Cloudflare response",
 			zap.Error(err),
 		)
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
-	logger.Info("📥 Received response from Cloudflare",
+	h.logger.Info("📥 Received response from Cloudflare",
 		zap.Int("status_code", resp.StatusCode),
 		zap.Int("body_size", len(body)),
 	)
 
 	// 检查响应状态
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		logger.Error("❌ Cloudflare API returned error",
+		h.logger.Error("❌ Cloudflare API returned error",
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("response", string(body)),
 		)
@@ -404,12 +751,12 @@ func PurgeCloudflareCache() error {
 	// 尝试解析响应以获取更多信息
 	var cfResponse map[string]interface{}
 	if err := json.Unmarshal(body, &cfResponse); err == nil {
-		logger.Info("✅ Cloudflare cache purged successfully!",
+		h.logger.Info("✅ Cloudflare cache purged successfully!",
 			zap.Int("status_code", resp.StatusCode),
 			zap.Any("cloudflare_response", cfResponse),
 		)
 	} else {
-		logger.Info("✅ Cloudflare cache purged successfully!",
+		h.logger.Info("✅ Cloudflare cache purged successfully!",
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("response", string(body)),
 		)
@@ -419,80 +766,36 @@ func PurgeCloudflareCache() error {
 }
 
 // HandleRefresh 手动刷新
-func HandleRefresh(w http.ResponseWriter, r *http.Request) {
-	password := r.URL.Query().Get("password")
-	if password != cfg.Auth.Password {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Password Error"))
+func (h *Handler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
 		return
 	}
 
-	logger.Info("Manual refresh triggered",
-		zap.String("remote_addr", r.RemoteAddr),
+	h.logger.Info("Manual refresh triggered",
+		zap.String("remote_addr", h.clientIP(r)),
 	)
 
-	var errors []string
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
-	// 刷新节点文件
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := fetcher.FetchNodeFile(); err != nil {
-			mu.Lock()
-			errors = append(errors, fmt.Sprintf("node file: %v", err))
-			mu.Unlock()
-		} else {
-			if err := ReloadData(); err != nil {
-				mu.Lock()
-				errors = append(errors, fmt.Sprintf("reload node data: %v", err))
-				mu.Unlock()
-			}
-		}
-	}()
-
-	// 刷新所有启用的模板
-	enabledTemplates := cfg.GetEnabledTemplates()
-	for name, tpl := range enabledTemplates {
-		wg.Add(1)
-		go func(templateName string, templateURL string) {
-			defer wg.Done()
-			if err := fetcher.FetchTemplateFileByName(templateName, templateURL); err != nil {
-				mu.Lock()
-				errors = append(errors, fmt.Sprintf("template %s: %v", templateName, err))
-				mu.Unlock()
-			} else {
-				if err := ReloadTemplateByName(templateName); err != nil {
-					mu.Lock()
-					errors = append(errors, fmt.Sprintf("reload template %s: %v", templateName, err))
-					mu.Unlock()
-				}
-			}
-		}(name, tpl.URL)
-	}
-
-	wg.Wait()
+	errors := h.refreshAll()
 
 	// 清理 Cloudflare 缓存（同步执行）
-	if cfg.Cloudflare.Enabled {
-		logger.Info("═══════════════════════════════════════════════")
-		logger.Info("🔄 Initiating Cloudflare cache purge...",
-			zap.String("remote_addr", r.RemoteAddr),
+	if h.cfg.Cloudflare.Enabled {
+		h.logger.Info("═══════════════════════════════════════════════")
+		h.logger.Info("🔄 Initiating Cloudflare cache purge...",
+			zap.String("remote_addr", h.clientIP(r)),
 			zap.String("trigger", "manual_refresh"),
 		)
-		if err := PurgeCloudflareCache(); err != nil {
+		if err := h.PurgeCloudflareCache(); err != nil {
 			errors = append(errors, fmt.Sprintf("cloudflare cache purge: %v", err))
-			logger.Error("❌ Cloudflare cache purge failed",
+			h.logger.Error("❌ Cloudflare cache purge failed",
 				zap.Error(err),
-				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("remote_addr", h.clientIP(r)),
 			)
 		} else {
-			logger.Info("🎉 Cloudflare cache purge completed successfully!")
+			h.logger.Info("🎉 Cloudflare cache purge completed successfully!")
 		}
-		logger.Info("═══════════════════════════════════════════════")
+		h.logger.Info("═══════════════════════════════════════════════")
 	} else {
-		logger.Debug("Cloudflare cache purge is disabled, skipping...")
+		h.logger.Debug("Cloudflare cache purge is disabled, skipping...")
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -500,58 +803,65 @@ func HandleRefresh(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		errJSON, _ := json.Marshal(errors)
 		fmt.Fprintf(w, `{"status":"error","errors":%s}`, string(errJSON))
-		logger.Error("Manual refresh failed",
+		h.logger.Error("Manual refresh failed",
 			zap.Strings("errors", errors),
 		)
 	} else {
-		dataMutex.RLock()
-		nodeCount := len(nodesData)
-		templateCount := len(templates)
-		dataMutex.RUnlock()
+		snap := h.loadSnapshot()
+		nodeCount := len(snap.nodesData)
+		templateCount := snap.templateCount()
 
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, `{"status":"success","message":"Files refreshed successfully","node_count":%d,"template_count":%d}`, nodeCount, templateCount)
-		logger.Info("Manual refresh completed successfully",
+		h.logger.Info("Manual refresh completed successfully",
 			zap.Int("node_count", nodeCount),
 			zap.Int("template_count", templateCount),
 		)
 	}
 }
 
-// nodeNameFilter 过滤节点名称
-func nodeNameFilter(param string) string {
-	dataMutex.RLock()
-	defer dataMutex.RUnlock()
-
-	filteredList := []string{}
-	if param == "" {
-		// 如果没有参数,返回所有节点名
-		filteredList = nodesName
-	} else {
-		// 按照 | 分隔的参数进行过滤
-		nameParams := strings.Split(param, "|")
-		for _, nodeName := range nodesName {
-			for _, name := range nameParams {
-				name = strings.TrimSpace(name)
-				if name != "" && strings.Contains(nodeName, name) {
-					filteredList = append(filteredList, nodeName)
-					break
-				}
-			}
-		}
+// filterNames 按筛选表达式过滤名称列表，并输出去掉外层 [] 的 JSON 字符串列表
+// 表达式已在模板过滤器中校验，此处解析失败时只记录日志并按无匹配节点处理
+func (s *Snapshot) filterNames(names []string, param string) string {
+	matched, err := s.matchNames(names, param)
+	if err != nil {
+		s.logger.Warn("Invalid node filter expression", zap.Error(err))
 	}
+	return s.formatNames(matched)
+}
 
+// formatNames 输出去掉外层 [] 的 JSON 字符串列表，列表为空时输出无节点标识
+func (s *Snapshot) formatNames(filteredList []string) string {
 	if len(filteredList) == 0 {
 		// 使用配置的无节点标识
-		noNodeName := cfg.GetDefaultTemplateNoNode()
-		filteredList = append(filteredList, noNodeName)
+		filteredList = append(filteredList, s.noNode)
 	}
 
 	jsonBytes, _ := json.Marshal(filteredList)
-	s := string(jsonBytes)
+	out := string(jsonBytes)
 	// 去掉外层的 []
-	if len(s) > 2 && s[0] == '[' && s[len(s)-1] == ']' {
-		return s[1 : len(s)-1]
+	if len(out) > 2 && out[0] == '[' && out[len(out)-1] == ']' {
+		return out[1 : len(out)-1]
+	}
+	return out
+}
+
+// matchNames 返回满足筛选表达式的名称，param 为空时返回全部
+func (s *Snapshot) matchNames(names []string, param string) ([]string, error) {
+	expr, err := nodeexpr.Parse(param)
+	if err != nil {
+		return []string{}, err
+	}
+
+	filteredList := []string{}
+	for _, name := range names {
+		node, ok := s.exprNodes[name]
+		if !ok {
+			node = nodeexpr.Node{Name: name}
+		}
+		if expr.Match(node) {
+			filteredList = append(filteredList, name)
+		}
 	}
-	return s
+	return filteredList, nil
 }
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/signer"
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+
+	"go.uber.org/zap"
+)
+
+// testTemplate 最小的文本模板，selector 引用全部节点
+const testTemplate = `{"outbounds":[{"type":"selector","tag":"proxy","outbounds":[{{ ""|NotesName }}]},{{ Nodes }}]}`
+
+// testNodeFile 生成只包含指定 tag 的 shadowsocks 节点文件
+func testNodeFile(tags ...string) string {
+	outbounds := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		outbounds = append(outbounds, fmt.Sprintf(
+			`{"type":"shadowsocks","tag":%q,"server":"%d.example.com","server_port":8388,"method":"aes-128-gcm","password":"pw"}`,
+			tag, i))
+	}
+	return `{"outbounds":[` + strings.Join(outbounds, ",") + `]}`
+}
+
+// newTestHandler 在临时缓存目录中写入节点与模板文件，按 c 创建 Handler
+func newTestHandler(t *testing.T, c *global.Config, nodeFile string) *Handler {
+	t.Helper()
+	c.Cache.Directory = t.TempDir()
+	c.DefaultTemplate = "default"
+	c.Templates = map[string]global.TemplateConfig{
+		"default": {Name: "default", NoNode: "direct", Enabled: true},
+	}
+	c.Subscriptions = []global.SubscriptionConfig{{Name: "main", Enabled: true}}
+	c.Security.MaxFailures = -1
+
+	writeTestFile(t, c.GetSubscriptionFilePathByName("main"), nodeFile)
+	writeTestFile(t, c.GetTemplateFilePathByName("default"), testTemplate)
+
+	h, err := New(c, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	return h
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s error: %v", path, err)
+	}
+}
+
+// serve 以固定客户端地址请求 Handler
+func serve(h http.HandlerFunc, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+// TestHandlersIsolated 两个使用不同配置的 Handler 同时运行，节点、鉴权、限流与重载互不影响
+func TestHandlersIsolated(t *testing.T) {
+	a := newTestHandler(t, &global.Config{Auth: global.AuthConfig{Password: "pw-a"}}, testNodeFile("node-a"))
+	b := newTestHandler(t, &global.Config{
+		Auth:  global.AuthConfig{Password: "pw-b"},
+		Users: []global.UserConfig{{Name: "bob", Token: "tok-b", Filter: "jp", Enabled: true}},
+	}, testNodeFile("node-b", "node-b-jp"))
+
+	tests := []struct {
+		name     string
+		handler  *Handler
+		query    string
+		code     int
+		contains []string
+		excludes []string
+	}{
+		{"a admin", a, "password=pw-a", http.StatusOK, []string{`"node-a"`}, []string{"node-b"}},
+		{"b admin", b, "password=pw-b", http.StatusOK, []string{`"node-b"`, `"node-b-jp"`}, []string{"node-a"}},
+		{"b password on a", a, "password=pw-b", http.StatusUnauthorized, nil, nil},
+		{"a password on b", b, "password=pw-a", http.StatusUnauthorized, nil, nil},
+		{"b user", b, "token=tok-b", http.StatusOK, []string{`"node-b-jp"`}, []string{`"node-b"`}},
+		{"b user on a", a, "token=tok-b", http.StatusUnauthorized, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.handler.HandleRequest, tt.query)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			body := rec.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("body missing %s: %s", s, body)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("body contains %s: %s", s, body)
+				}
+			}
+		})
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		writeTestFile(t, a.cfg.GetSubscriptionFilePathByName("main"), testNodeFile("node-a2"))
+		if err := a.ReloadData(); err != nil {
+			t.Fatalf("ReloadData error: %v", err)
+		}
+		if got := a.loadSnapshot().nodesName; len(got) != 1 || got[0] != "node-a2" {
+			t.Errorf("a nodes = %v, want [node-a2]", got)
+		}
+		if got := b.loadSnapshot().nodesName; len(got) != 2 || got[0] != "node-b" {
+			t.Errorf("b nodes = %v, want [node-b node-b-jp]", got)
+		}
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		a.cfg.Security.MaxFailures = 1
+		serve(a.HandleRequest, "password=wrong")
+		if rec := serve(a.HandleRequest, "password=pw-a"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("a status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+		}
+		if rec := serve(b.HandleRequest, "password=pw-b"); rec.Code != http.StatusOK {
+			t.Errorf("b status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+}
+
+// TestReloadIfChanged 文件监控触发的重载在文件已加载时跳过，文件被再次写入后重新加载
+func TestReloadIfChanged(t *testing.T) {
+	h := newTestHandler(t, &global.Config{Auth: global.AuthConfig{Password: "pw"}}, testNodeFile("node"))
+
+	loaded := h.loadSnapshot()
+	if err := h.ReloadDataIfChanged(); err != nil {
+		t.Fatalf("ReloadDataIfChanged error: %v", err)
+	}
+	if err := h.ReloadTemplateIfChanged("default"); err != nil {
+		t.Fatalf("ReloadTemplateIfChanged error: %v", err)
+	}
+	if h.loadSnapshot() != loaded {
+		t.Fatal("snapshot replaced although files were already loaded")
+	}
+
+	writeTestFile(t, h.cfg.GetSubscriptionFilePathByName("main"), testNodeFile("node", "node-2"))
+	if err := h.ReloadDataIfChanged(); err != nil {
+		t.Fatalf("ReloadDataIfChanged error: %v", err)
+	}
+	if got := h.loadSnapshot().nodesName; len(got) != 2 {
+		t.Errorf("nodes = %v, want 2 nodes after file changed", got)
+	}
+}
+
+// TestAuthenticate 用户 token（明文与 bcrypt 的 name.token 形式）与签名链接的鉴权及过期处理
+func TestAuthenticate(t *testing.T) {
+	hash, err := util.GeneratePasswordHash("tok-dave")
+	if err != nil {
+		t.Fatalf("hash error: %v", err)
+	}
+	h := newTestHandler(t, &global.Config{
+		Auth: global.AuthConfig{Password: "pw", SigningKey: "key"},
+		Users: []global.UserConfig{
+			{Name: "alice", Token: "tok-alice", Enabled: true},
+			{Name: "bob", Token: "tok-bob", ExpiresAt: "2000-01-01", Enabled: true},
+			{Name: "dave", Token: hash, Enabled: true},
+		},
+	}, testNodeFile("node"))
+
+	sign := func(template string, expiresAt time.Time) string {
+		token, err := signer.Sign("key", signer.Claims{Template: template, ExpiresAt: expiresAt.Unix()})
+		if err != nil {
+			t.Fatalf("Sign error: %v", err)
+		}
+		return url.QueryEscape(token)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		body  string
+	}{
+		{"plaintext token", "token=tok-alice", http.StatusOK, ""},
+		{"plaintext token again from cache", "token=tok-alice", http.StatusOK, ""},
+		{"expired user", "token=tok-bob", http.StatusUnauthorized, "Token Expired"},
+		{"bcrypt name.token", "token=dave.tok-dave", http.StatusOK, ""},
+		{"bcrypt token without name", "token=tok-dave", http.StatusUnauthorized, "Password Error"},
+		{"signed link", "sign=" + sign("default", time.Now().Add(time.Hour)), http.StatusOK, ""},
+		{"expired signed link", "sign=" + sign("default", time.Now().Add(-time.Second)), http.StatusUnauthorized, "Token Expired"},
+		{"signed link used for another template", "template=other&sign=" + sign("default", time.Now().Add(time.Hour)), http.StatusForbidden, ""},
+		{"tampered signed link", "sign=" + sign("default", time.Now().Add(time.Hour)) + "x", http.StatusUnauthorized, "Password Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h.HandleRequest, tt.query)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache 有容量上限的并发安全 LRU 缓存，超出容量时淘汰最久未使用的条目。
+// 缓存键包含客户端传入的 type 参数，限制容量以免任意取值占满内存
+type lruCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 最近使用的在前
+	items    map[K]*list.Element
+}
+
+// lruItem 链表中保存的条目
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// newLRUCache 创建指定容量的 LRU 缓存
+func newLRUCache[K comparable, V any](capacity int) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[K]*list.Element),
+	}
+}
+
+// get 读取条目并标记为最近使用
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*lruItem[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// put 写入条目，超出容量时淘汰最久未使用的条目
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*lruItem[K, V]).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem[K, V]{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem[K, V]).key)
+	}
+}
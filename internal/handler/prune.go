@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pruneSingbox 从渲染后的 sing-box 配置中移除隐藏的节点：
+// 删除对应的 outbound，并从策略组的 outbounds 中移除引用，策略组为空时填入 noNodeName
+func pruneSingbox(output string, hidden map[string]bool, noNodeName string) (string, error) {
+	if len(hidden) == 0 {
+		return output, nil
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &doc); err != nil {
+		return "", fmt.Errorf("invalid rendered config: %w", err)
+	}
+	outbounds, ok := doc["outbounds"].([]interface{})
+	if !ok {
+		return output, nil
+	}
+
+	kept := make([]interface{}, 0, len(outbounds))
+	for _, item := range outbounds {
+		outbound, ok := item.(map[string]interface{})
+		if !ok {
+			kept = append(kept, item)
+			continue
+		}
+		if tag, _ := outbound["tag"].(string); hidden[tag] {
+			continue
+		}
+
+		if refs, ok := outbound["outbounds"].([]interface{}); ok {
+			filtered := make([]interface{}, 0, len(refs))
+			for _, ref := range refs {
+				if tag, _ := ref.(string); !hidden[tag] {
+					filtered = append(filtered, ref)
+				}
+			}
+			if len(filtered) == 0 && len(refs) > 0 {
+				filtered = append(filtered, noNodeName)
+			}
+			outbound["outbounds"] = filtered
+		}
+		kept = append(kept, outbound)
+	}
+	doc["outbounds"] = kept
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// pruneClash 从渲染后的 Clash 配置中移除隐藏的节点，保留原有字段顺序
+func pruneClash(output []byte, hidden map[string]bool, noNodeName string) ([]byte, error) {
+	if len(hidden) == 0 {
+		return output, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(output, &root); err != nil {
+		return nil, fmt.Errorf("invalid rendered clash config: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return output, nil
+	}
+	doc := root.Content[0]
+
+	if proxies := mappingValue(doc, "proxies"); proxies != nil && proxies.Kind == yaml.SequenceNode {
+		kept := proxies.Content[:0]
+		for _, proxy := range proxies.Content {
+			if name := mappingValue(proxy, "name"); name != nil && hidden[name.Value] {
+				continue
+			}
+			kept = append(kept, proxy)
+		}
+		proxies.Content = kept
+	}
+
+	if groups := mappingValue(doc, "proxy-groups"); groups != nil && groups.Kind == yaml.SequenceNode {
+		for _, group := range groups.Content {
+			refs := mappingValue(group, "proxies")
+			if refs == nil || refs.Kind != yaml.SequenceNode || len(refs.Content) == 0 {
+				continue
+			}
+			kept := refs.Content[:0]
+			for _, ref := range refs.Content {
+				if !hidden[ref.Value] {
+					kept = append(kept, ref)
+				}
+			}
+			if len(kept) == 0 {
+				kept = append(kept, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: noNodeName})
+			}
+			refs.Content = kept
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue 读取 YAML mapping 节点中指定键的值
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
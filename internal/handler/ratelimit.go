@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// authFailure 单个客户端 IP 的鉴权失败记录
+type authFailure struct {
+	count       int
+	first       time.Time // 当前计数窗口的起始时间
+	lockedUntil time.Time
+}
+
+// rateBucket 令牌桶，容量为每分钟允许的请求数
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// clientIP 识别客户端 IP：直连地址属于可信代理时，优先使用 CF-Connecting-IP，
+// 其次从右向左取 X-Forwarded-For 中第一个非可信代理的地址
+func (h *Handler) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	trusted := h.trustedProxies
+	if !isTrustedProxy(host, trusted) {
+		return host
+	}
+
+	if cf := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); net.ParseIP(cf) != nil {
+		return cf
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !isTrustedProxy(hop, trusted) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// isTrustedProxy 判断地址是否属于可信代理
+func isTrustedProxy(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockedOut 返回客户端 IP 是否处于锁定状态及剩余锁定时间
+func (h *Handler) lockedOut(ip string) (time.Duration, bool) {
+	h.limitMutex.Lock()
+	defer h.limitMutex.Unlock()
+
+	record, ok := h.authFailures[ip]
+	if !ok {
+		return 0, false
+	}
+	if wait := time.Until(record.lockedUntil); wait > 0 {
+		return wait, true
+	}
+	return 0, false
+}
+
+// recordAuthFailure 记录一次鉴权失败，达到上限时锁定该 IP
+func (h *Handler) recordAuthFailure(ip string) {
+	maxFailures := h.cfg.GetMaxAuthFailures()
+	if maxFailures == 0 {
+		return
+	}
+
+	h.limitMutex.Lock()
+	defer h.limitMutex.Unlock()
+
+	now := time.Now()
+	h.sweepLimits(now)
+
+	record, ok := h.authFailures[ip]
+	if !ok || now.Sub(record.first) > h.cfg.GetFailureWindow() {
+		record = &authFailure{first: now}
+		h.authFailures[ip] = record
+	}
+	record.count++
+	if record.count >= maxFailures {
+		record.lockedUntil = now.Add(h.cfg.GetLockoutDuration())
+		record.count = 0
+		record.first = now
+		h.logger.Warn("Client locked out after repeated auth failures",
+			zap.String("remote_addr", ip),
+			zap.Int("failures", maxFailures),
+			zap.Time("locked_until", record.lockedUntil),
+		)
+	}
+}
+
+// clearAuthFailures 清除客户端 IP 的失败记录
+func (h *Handler) clearAuthFailures(ip string) {
+	h.limitMutex.Lock()
+	defer h.limitMutex.Unlock()
+	delete(h.authFailures, ip)
+}
+
+// allowRequest 按令牌桶校验 key 的请求频率，perMinute 为 0 时不限制；
+// 超出限制时返回需要等待的时间
+func (h *Handler) allowRequest(key string, perMinute int) (time.Duration, bool) {
+	if perMinute <= 0 {
+		return 0, true
+	}
+
+	h.limitMutex.Lock()
+	defer h.limitMutex.Unlock()
+
+	now := time.Now()
+	h.sweepLimits(now)
+
+	capacity := float64(perMinute)
+	perSecond := capacity / 60
+	bucket, ok := h.rateBuckets[key]
+	if !ok {
+		bucket = &rateBucket{tokens: capacity, updated: now}
+		h.rateBuckets[key] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second)), false
+	}
+	bucket.tokens--
+	return 0, true
+}
+
+// sweepLimits 每分钟清理一次过期的失败记录与已回满的令牌桶，调用方需持有 limitMutex
+func (h *Handler) sweepLimits(now time.Time) {
+	if now.Sub(h.lastSweep) < time.Minute {
+		return
+	}
+	h.lastSweep = now
+
+	window := h.cfg.GetFailureWindow()
+	for ip, record := range h.authFailures {
+		if now.After(record.lockedUntil) && now.Sub(record.first) > window {
+			delete(h.authFailures, ip)
+		}
+	}
+	for key, bucket := range h.rateBuckets {
+		if now.Sub(bucket.updated) > time.Minute {
+			delete(h.rateBuckets, key)
+		}
+	}
+}
+
+// writeTooManyRequests 输出 429 响应
+func (h *Handler) writeTooManyRequests(w http.ResponseWriter, r *http.Request, ip string, wait time.Duration, reason string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte("Too Many Requests"))
+	h.logger.Warn("Request throttled",
+		zap.String("remote_addr", ip),
+		zap.String("path", r.URL.Path),
+		zap.String("reason", reason),
+		zap.Int("retry_after", seconds),
+	)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/fetcher"
+
+	"go.uber.org/zap"
+)
+
+// refreshAll 拉取所有订阅源与模板并重新加载，返回错误列表；
+// 刷新进行中时新的调用等待并共享同一次结果，上游拉取的冷却由 fetcher 控制
+func (h *Handler) refreshAll() []string {
+	result, _, shared := h.refreshGroup.Do("all", func() (interface{}, error) {
+		return h.performRefresh(), nil
+	})
+	if shared {
+		h.logger.Info("Joined in-flight refresh")
+	}
+	return result.([]string)
+}
+
+// performRefresh 并发拉取所有订阅源与模板，全部完成后统一重新加载；手动刷新会解除回滚后的固定
+func (h *Handler) performRefresh() []string {
+	h.refreshMutex.Lock()
+	defer h.refreshMutex.Unlock()
+
+	fetcher.UnpinAll()
+
+	var errors []string
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	// 刷新所有订阅源，全部拉取完成后统一合并重载
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		changed, fetchErrors := fetcher.FetchAllSubscriptions()
+		mu.Lock()
+		for name, err := range fetchErrors {
+			errors = append(errors, fmt.Sprintf("subscription %s: %v", name, err))
+		}
+		mu.Unlock()
+		// 所有订阅源均未变化（如上游返回 304）时无需重新加载
+		if changed {
+			if err := h.ReloadData(); err != nil {
+				mu.Lock()
+				errors = append(errors, fmt.Sprintf("reload node data: %v", err))
+				mu.Unlock()
+			}
+		}
+	}()
+
+	// 刷新所有启用的模板
+	var tplWg sync.WaitGroup
+	var templatesChanged bool
+	for name, tpl := range h.cfg.GetEnabledTemplates() {
+		tplWg.Add(1)
+		go func(templateName string, tplConfig global.TemplateConfig) {
+			defer tplWg.Done()
+			changed, err := fetcher.FetchTemplate(templateName, tplConfig)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("template %s: %v", templateName, err))
+			}
+			templatesChanged = templatesChanged || changed
+		}(name, tpl)
+	}
+
+	// 模板全部拉取后，有变化时一次性重新加载
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tplWg.Wait()
+		mu.Lock()
+		changed := templatesChanged
+		mu.Unlock()
+		if !changed {
+			return
+		}
+		if err := h.ReloadAllTemplates(); err != nil {
+			mu.Lock()
+			errors = append(errors, fmt.Sprintf("reload templates: %v", err))
+			mu.Unlock()
+		}
+	}()
+
+	wg.Wait()
+	return errors
+}
+
+// ReloadDataIfChanged 文件监控触发的节点重载：等待进行中的全量刷新完成，
+// 节点文件与当前 Snapshot 加载时一致（已由刷新或回滚统一重载）时跳过
+func (h *Handler) ReloadDataIfChanged() error {
+	h.refreshMutex.Lock()
+	h.refreshMutex.Unlock()
+
+	var paths []string
+	for _, sub := range h.cfg.GetEnabledSubscriptions() {
+		paths = append(paths, h.cfg.GetSubscriptionFilePathByName(sub.Name))
+	}
+	if !h.loadSnapshot().changedSince(paths...) {
+		h.logger.Debug("Node files already loaded, skipping reload")
+		return nil
+	}
+	return h.ReloadData()
+}
+
+// ReloadTemplateIfChanged 文件监控触发的模板重载，规则同 ReloadDataIfChanged
+func (h *Handler) ReloadTemplateIfChanged(templateName string) error {
+	h.refreshMutex.Lock()
+	h.refreshMutex.Unlock()
+
+	paths := []string{h.cfg.GetTemplateFilePathByName(templateName)}
+	if tpl, ok := h.cfg.GetTemplate(templateName); ok && tpl.ClashURL != "" {
+		paths = append(paths, h.cfg.GetClashTemplateFilePathByName(templateName))
+	}
+	if !h.loadSnapshot().changedSince(paths...) {
+		h.logger.Debug("Template files already loaded, skipping reload", zap.String("template", templateName))
+		return nil
+	}
+	return h.ReloadTemplateByName(templateName)
+}
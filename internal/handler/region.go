@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/region"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/rename"
+)
+
+// nodeInfo 节点元数据
+type nodeInfo struct {
+	Name       string      `json:"name"`
+	Source     string      `json:"source"`
+	Type       string      `json:"type"`
+	Server     string      `json:"server,omitempty"`
+	ServerPort interface{} `json:"server_port,omitempty"`
+	Region     string      `json:"region,omitempty"`
+	Flag       string      `json:"flag,omitempty"`
+}
+
+// classifyRegions 识别节点池中各节点的地区
+func classifyRegions(pool []rename.Node) map[string]string {
+	regions := make(map[string]string, len(pool))
+	for _, node := range pool {
+		tag, _ := node.Outbound["tag"].(string)
+		server, _ := node.Outbound["server"].(string)
+		if code := region.Classify(tag, server); code != "" {
+			regions[tag] = code
+		}
+	}
+	return regions
+}
+
+// matchRegions 返回地区属于 | 分隔的任一地区代码的名称
+func (s *Snapshot) matchRegions(names []string, param string) []string {
+	codes := make(map[string]bool)
+	for _, code := range strings.Split(param, "|") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			codes[code] = true
+		}
+	}
+
+	matched := []string{}
+	for _, name := range names {
+		if codes[s.regions[name]] {
+			matched = append(matched, name)
+		}
+	}
+	return matched
+}
+
+// HandleNodes 节点元数据接口，列出节点池中各节点的来源、类型、服务器与识别出的地区
+func (h *Handler) HandleNodes(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	snap := h.loadSnapshot()
+	nodes := make([]nodeInfo, 0, len(snap.nodesData))
+	counts := make(map[string]int)
+	for _, node := range snap.nodesData {
+		tag, _ := node["tag"].(string)
+		outboundType, _ := node["type"].(string)
+		server, _ := node["server"].(string)
+		code := snap.regions[tag]
+		nodes = append(nodes, nodeInfo{
+			Name:       tag,
+			Source:     snap.nodeSources[tag],
+			Type:       outboundType,
+			Server:     server,
+			ServerPort: node["server_port"],
+			Region:     code,
+			Flag:       region.Flag(code),
+		})
+		if code == "" {
+			code = "unknown"
+		}
+		counts[code]++
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"count":   len(nodes),
+		"regions": counts,
+		"nodes":   nodes,
+	})
+}
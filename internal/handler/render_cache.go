@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"go.uber.org/zap"
+)
+
+// minCompressSize 小于该大小的响应不压缩
+const minCompressSize = 512
+
+// maxRenderEntries 单个 Snapshot 缓存的渲染结果数量上限，超出时淘汰最久未使用的结果
+const maxRenderEntries = 256
+
+// renderEntry 缓存的渲染结果，压缩结果按需生成后一并缓存
+type renderEntry struct {
+	body        []byte
+	contentType string
+	nodeCount   int
+	etag        string // 内容哈希，不含引号
+
+	encodeMutex sync.Mutex
+	encoded     map[string][]byte // Content-Encoding -> 压缩后的内容
+}
+
+// renderKey 渲染缓存键，同一用户（或同一签名过滤）的相同请求共享缓存
+type renderKey struct {
+	user     string
+	template string
+	format   string
+	setType  string
+}
+
+// renderCache 单个 Snapshot 的渲染结果缓存，Snapshot 替换后随之失效
+type renderCache struct {
+	entries *lruCache[renderKey, *renderEntry]
+}
+
+// newRenderCache 创建渲染缓存
+func newRenderCache() *renderCache {
+	return &renderCache{entries: newLRUCache[renderKey, *renderEntry](maxRenderEntries)}
+}
+
+// newRenderEntry 创建渲染结果，ETag 为内容的 SHA-256 摘要
+func newRenderEntry(body []byte, contentType string, nodeCount int) *renderEntry {
+	sum := sha256.Sum256(body)
+	return &renderEntry{
+		body:        body,
+		contentType: contentType,
+		nodeCount:   nodeCount,
+		etag:        hex.EncodeToString(sum[:16]),
+	}
+}
+
+// newRenderKey 生成渲染缓存键
+func newRenderKey(p principal, templateName, format, setType string) renderKey {
+	return renderKey{user: p.name(), template: templateName, format: format, setType: setType}
+}
+
+// get 读取缓存的渲染结果
+func (c *renderCache) get(key renderKey) (*renderEntry, bool) {
+	return c.entries.get(key)
+}
+
+// put 缓存渲染结果
+func (c *renderCache) put(key renderKey, entry *renderEntry) {
+	c.entries.put(key, entry)
+}
+
+// writeRender 输出渲染结果：设置订阅 Header 与 ETag，If-None-Match 命中时返回 304，
+// 启用压缩时按 Accept-Encoding 选择 br 或 gzip
+func (h *Handler) writeRender(w http.ResponseWriter, r *http.Request, entry *renderEntry) {
+	setSubscriptionHeaders(w, entry.contentType, entry.nodeCount)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := ""
+	if h.cfg.Server.Compression && len(entry.body) >= minCompressSize {
+		encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	}
+
+	etag := entry.etag
+	if encoding != "" {
+		etag += "-" + encoding
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+
+	if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := entry.body
+	if encoding != "" {
+		compressed, err := entry.encode(encoding)
+		if err != nil {
+			h.logger.Warn("Failed to compress response", zap.String("encoding", encoding), zap.Error(err))
+			w.Header().Set("ETag", `"`+entry.etag+`"`)
+		} else {
+			w.Header().Set("Content-Encoding", encoding)
+			body = compressed
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// encode 返回指定编码的压缩结果，首次调用时生成并缓存
+func (e *renderEntry) encode(encoding string) ([]byte, error) {
+	e.encodeMutex.Lock()
+	defer e.encodeMutex.Unlock()
+
+	if data, ok := e.encoded[encoding]; ok {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch encoding {
+	case "br":
+		bw := brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+		if _, err = bw.Write(e.body); err == nil {
+			err = bw.Close()
+		}
+	default:
+		gw := gzip.NewWriter(&buf)
+		if _, err = gw.Write(e.body); err == nil {
+			err = gw.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if e.encoded == nil {
+		e.encoded = make(map[string][]byte)
+	}
+	e.encoded[encoding] = buf.Bytes()
+	return buf.Bytes(), nil
+}
+
+// negotiateEncoding 根据 Accept-Encoding 选择压缩方式，优先 br，其次 gzip
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		if q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+			continue
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	for _, encoding := range []string{"br", "gzip"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// etagMatches 判断 If-None-Match 是否包含指定内容哈希，忽略弱校验前缀与压缩后缀
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(part)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+		tag, _, _ = strings.Cut(tag, "-")
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/signer"
+
+	"go.uber.org/zap"
+)
+
+// defaultSignTTL 签名链接默认有效期
+const defaultSignTTL = "7d"
+
+// HandleSign 生成签名订阅链接（仅管理员）
+// 参数：template 模板（默认 default_template）、filter 节点过滤、ttl 有效期（RFC3339 / 72h / 7d，默认 7d），
+// type、format 会原样附加到链接中
+func (h *Handler) HandleSign(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	if h.cfg.Auth.SigningKey == "" {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("auth.signing_key is not configured"))
+		return
+	}
+
+	query := r.URL.Query()
+	templateName := query.Get("template")
+	if templateName == "" {
+		templateName = h.cfg.DefaultTemplate
+	}
+	if tpl, exists := h.cfg.GetTemplate(templateName); !exists || !tpl.Enabled {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("template '%s' not found or not enabled", templateName))
+		return
+	}
+
+	ttl := query.Get("ttl")
+	if ttl == "" {
+		ttl = defaultSignTTL
+	}
+	expiresAt, err := signer.ParseExpiry(ttl, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	extra := url.Values{}
+	for _, key := range []string{"type", "format"} {
+		if v := query.Get(key); v != "" {
+			extra.Set(key, v)
+		}
+	}
+
+	filter := query.Get("filter")
+	if err := nodeexpr.Validate(filter); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	claims := signer.Claims{
+		Template:  templateName,
+		Filter:    filter,
+		ExpiresAt: expiresAt.Unix(),
+	}
+	link, err := signer.BuildURL(requestBaseURL(r), h.cfg.Auth.SigningKey, claims, extra)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.logger.Info("Signed link created",
+		zap.String("remote_addr", h.clientIP(r)),
+		zap.String("template", claims.Template),
+		zap.String("filter", claims.Filter),
+		zap.Time("expires_at", claims.Expiry()),
+	)
+
+	writeJSON(w, map[string]interface{}{
+		"status":     "success",
+		"url":        link,
+		"template":   claims.Template,
+		"filter":     claims.Filter,
+		"expires_at": claims.Expiry().UTC().Format(time.RFC3339),
+	})
+}
+
+// requestBaseURL 根据请求推断服务的访问地址
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/"
+}
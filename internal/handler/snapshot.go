@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/fetcher"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/snapshot"
+
+	"go.uber.org/zap"
+)
+
+// snapshotList 快照列表响应
+type snapshotList struct {
+	Kind      string              `json:"kind"`
+	Name      string              `json:"name"`
+	Snapshots []snapshot.Snapshot `json:"snapshots"`
+	Pinned    *fetcher.Pin        `json:"pinned,omitempty"` // 回滚后固定，自动更新不会覆盖
+}
+
+// loadPin 读取目标的固定状态，未固定时返回 nil
+func loadPin(target snapshot.Target) *fetcher.Pin {
+	if pin, ok := fetcher.LoadPin(target.Path); ok {
+		return &pin
+	}
+	return nil
+}
+
+// RollbackSnapshot 将缓存文件回滚到指定快照并重新加载
+func (h *Handler) RollbackSnapshot(target snapshot.Target, id string) error {
+	if err := fetcher.RestoreSnapshot(target, id); err != nil {
+		return err
+	}
+	if target.Kind == snapshot.KindNode {
+		return h.ReloadData()
+	}
+	return h.ReloadTemplateByName(target.Name)
+}
+
+// HandleSnapshots 快照管理接口
+// action=list（默认）列出快照，action=diff 比较两个快照，action=rollback（POST）回滚到指定快照并固定，
+// action=unpin（POST）解除固定，恢复自动更新
+func (h *Handler) HandleSnapshots(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	kind := query.Get("kind")
+	name := query.Get("name")
+	action := query.Get("action")
+
+	// 未指定目标时列出全部订阅源与模板的快照
+	if kind == "" && name == "" && (action == "" || action == "list") {
+		var lists []snapshotList
+		for _, target := range snapshot.Targets() {
+			snapshots, err := snapshot.List(target.Path)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err)
+				return
+			}
+			lists = append(lists, snapshotList{Kind: target.Kind, Name: target.Name, Snapshots: snapshots, Pinned: loadPin(target)})
+		}
+		writeJSON(w, map[string]interface{}{"status": "success", "targets": lists})
+		return
+	}
+
+	target, err := snapshot.Resolve(kind, name)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch action {
+	case "", "list":
+		snapshots, err := snapshot.List(target.Path)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"status":    "success",
+			"kind":      target.Kind,
+			"name":      target.Name,
+			"snapshots": snapshots,
+			"pinned":    loadPin(target),
+		})
+
+	case "diff":
+		from, to := query.Get("from"), query.Get("to")
+		if from == "" {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("missing from"))
+			return
+		}
+		if to == "" {
+			to = snapshot.Current
+		}
+		diff, err := snapshot.Diff(target.Path, from, to)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(diff))
+
+	case "rollback":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("rollback requires POST"))
+			return
+		}
+		id := query.Get("id")
+		if id == "" {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("missing id"))
+			return
+		}
+		if err := h.RollbackSnapshot(target, id); err != nil {
+			h.logger.Error("Snapshot rollback failed",
+				zap.String("kind", target.Kind),
+				zap.String("name", target.Name),
+				zap.String("snapshot", id),
+				zap.Error(err),
+			)
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		h.logger.Info("Snapshot rolled back",
+			zap.String("remote_addr", h.clientIP(r)),
+			zap.String("kind", target.Kind),
+			zap.String("name", target.Name),
+			zap.String("snapshot", id),
+		)
+		writeJSON(w, map[string]interface{}{
+			"status":   "success",
+			"kind":     target.Kind,
+			"name":     target.Name,
+			"snapshot": id,
+			"pinned":   loadPin(target),
+		})
+
+	case "unpin":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("unpin requires POST"))
+			return
+		}
+		unpinned, err := fetcher.Unpin(target)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		h.logger.Info("Snapshot pin cleared",
+			zap.String("remote_addr", h.clientIP(r)),
+			zap.String("kind", target.Kind),
+			zap.String("name", target.Name),
+			zap.Bool("was_pinned", unpinned),
+		)
+		writeJSON(w, map[string]interface{}{
+			"status":   "success",
+			"kind":     target.Kind,
+			"name":     target.Name,
+			"unpinned": unpinned,
+		})
+
+	default:
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid action: %s", action))
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"go.uber.org/zap"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+)
+
+// Snapshot 一次加载得到的完整数据：节点与编译后的模板。
+// 发布后只读，重载时构建新的 Snapshot 并整体替换，请求始终使用同一个 Snapshot 完成渲染
+type Snapshot struct {
+	nodesName []string                 // 节点 tag，保持订阅源顺序
+	nodesData []map[string]interface{} // 节点 outbound
+	nodesJSON string                   // 节点 outbound 的 JSON，以 ",\r\n" 连接，用于 Nodes 模板变量
+
+	clashProxies []map[string]interface{}
+	clashNames   []string
+
+	templates      map[string]*pongo2.Template
+	jsonTemplates  map[string][]byte
+	clashTemplates map[string]*pongo2.Template
+
+	sources     []SourceStatus
+	nodeSources map[string]string        // 节点名称 -> 所属订阅源
+	regions     map[string]string        // 节点名称 -> 地区代码（ISO 3166-1 alpha-2），无法识别的节点不包含在内
+	exprNodes   map[string]nodeexpr.Node // 节点名称 -> 筛选表达式使用的节点属性
+
+	templateHidden map[string]map[string]bool // 各模板 node_filter 隐藏的节点名称
+	filters        filterReport
+
+	renders *renderCache         // 基于该 Snapshot 的渲染结果，随 Snapshot 一同失效
+	files   map[string]fileStamp // 已加载的缓存文件路径 -> 加载时的修改时间与大小
+
+	noNode string      // 名称列表为空时输出的无节点标识，取自默认模板
+	logger *zap.Logger // 所属 Handler 的日志
+}
+
+// newSnapshot 创建空的 Snapshot
+func (h *Handler) newSnapshot() *Snapshot {
+	return &Snapshot{
+		noNode:         h.cfg.GetDefaultTemplateNoNode(),
+		logger:         h.logger,
+		templates:      make(map[string]*pongo2.Template),
+		jsonTemplates:  make(map[string][]byte),
+		clashTemplates: make(map[string]*pongo2.Template),
+		renders:        newRenderCache(),
+		files:          make(map[string]fileStamp),
+	}
+}
+
+// loadSnapshot 读取当前 Snapshot，未初始化时返回空 Snapshot
+func (h *Handler) loadSnapshot() *Snapshot {
+	if s := h.current.Load(); s != nil {
+		return s
+	}
+	return h.newSnapshot()
+}
+
+// clone 浅拷贝 Snapshot 用于构建下一个版本，模板映射单独复制，渲染缓存重新创建
+func (s *Snapshot) clone() *Snapshot {
+	next := *s
+	next.templates = make(map[string]*pongo2.Template, len(s.templates))
+	for name, tpl := range s.templates {
+		next.templates[name] = tpl
+	}
+	next.jsonTemplates = make(map[string][]byte, len(s.jsonTemplates))
+	for name, data := range s.jsonTemplates {
+		next.jsonTemplates[name] = data
+	}
+	next.clashTemplates = make(map[string]*pongo2.Template, len(s.clashTemplates))
+	for name, tpl := range s.clashTemplates {
+		next.clashTemplates[name] = tpl
+	}
+	next.files = make(map[string]fileStamp, len(s.files))
+	for path, stamp := range s.files {
+		next.files[path] = stamp
+	}
+	next.renders = newRenderCache()
+	return &next
+}
+
+// fileStamp 缓存文件的修改时间与大小，用于判断文件在加载后是否被再次写入
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile 读取文件的 fileStamp，文件不存在时返回零值
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// changedSince 判断 paths 中是否有文件与该 Snapshot 加载时不同
+func (s *Snapshot) changedSince(paths ...string) bool {
+	for _, path := range paths {
+		loaded := s.files[path]
+		stamp := statFile(path)
+		if !stamp.modTime.Equal(loaded.modTime) || stamp.size != loaded.size {
+			return true
+		}
+	}
+	return false
+}
+
+// templateCount 已加载的模板数量
+func (s *Snapshot) templateCount() int {
+	return len(s.templates) + len(s.jsonTemplates)
+}
+
+// 模板过滤器占位符：pongo2 过滤器是全局的，无法获取当前请求使用的 Snapshot，
+// 因此过滤器只输出占位符，渲染完成后再由同一个 Snapshot 展开为节点名称
+const (
+	markerStart = "\x00"
+	markerSep   = "\x01"
+	markerEnd   = "\x02"
+
+	markerNodes        = "NotesName"
+	markerProxies      = "ProxyNames"
+	markerRegions      = "RegionNames"
+	markerProxyRegions = "ProxyRegionNames"
+)
+
+// nameMarker 生成名称过滤占位符
+func nameMarker(kind, param string) string {
+	return markerStart + kind + markerSep + param + markerEnd
+}
+
+// expandNames 将渲染结果中的占位符展开为该 Snapshot 中匹配的节点名称
+func (s *Snapshot) expandNames(output string) string {
+	if !strings.Contains(output, markerStart) {
+		return output
+	}
+
+	var b strings.Builder
+	b.Grow(len(output))
+	for {
+		start := strings.Index(output, markerStart)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(output[start:], markerEnd)
+		if end < 0 {
+			break
+		}
+		kind, param, _ := strings.Cut(output[start+len(markerStart):start+end], markerSep)
+
+		b.WriteString(output[:start])
+		switch kind {
+		case markerProxies:
+			b.WriteString(s.filterNames(s.clashNames, param))
+		case markerRegions:
+			b.WriteString(s.formatNames(s.matchRegions(s.nodesName, param)))
+		case markerProxyRegions:
+			b.WriteString(s.formatNames(s.matchRegions(s.clashNames, param)))
+		default:
+			b.WriteString(s.filterNames(s.nodesName, param))
+		}
+		output = output[start+end+len(markerEnd):]
+	}
+	b.WriteString(output)
+	return b.String()
+}
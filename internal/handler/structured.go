@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+)
+
+// filterKey 结构化模板中标记需要填充节点 tag 的字段
+const filterKey = "$filter"
+
+// loadStructuredTemplate 读取结构化模板并校验为合法 JSON，同时校验 $filter 表达式语法
+func loadStructuredTemplate(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid json template: %w", err)
+	}
+
+	outbounds, _ := doc["outbounds"].([]interface{})
+	for _, item := range outbounds {
+		group, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if param, ok := group[filterKey].(string); ok {
+			if err := nodeexpr.Validate(param); err != nil {
+				return nil, fmt.Errorf("outbound '%v': %s %w", group["tag"], filterKey, err)
+			}
+		}
+	}
+	return data, nil
+}
+
+// renderStructured 渲染结构化 JSON 模板
+// 带有 "$filter" 的 outbound 会将匹配的节点 tag 追加到其 outbounds 列表，
+// 所有节点 outbound 追加到顶层 outbounds 末尾
+func (s *Snapshot) renderStructured(tplData []byte, noNodeName string) (string, error) {
+	// 每次渲染都重新解析，避免修改缓存的模板
+	var doc map[string]interface{}
+	if err := json.Unmarshal(tplData, &doc); err != nil {
+		return "", fmt.Errorf("invalid json template: %w", err)
+	}
+
+	outbounds, ok := doc["outbounds"].([]interface{})
+	if !ok && doc["outbounds"] != nil {
+		return "", fmt.Errorf("template outbounds must be an array")
+	}
+
+	if noNodeName == "" {
+		noNodeName = s.noNode
+	}
+
+	for _, item := range outbounds {
+		group, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		raw, exists := group[filterKey]
+		if !exists {
+			continue
+		}
+		param, ok := raw.(string)
+		if !ok {
+			return "", fmt.Errorf("outbound '%v': %s must be a string", group["tag"], filterKey)
+		}
+		delete(group, filterKey)
+
+		matched, err := s.matchNames(s.nodesName, param)
+		if err != nil {
+			return "", fmt.Errorf("outbound '%v': %s %w", group["tag"], filterKey, err)
+		}
+		if len(matched) == 0 {
+			matched = []string{noNodeName}
+		}
+
+		existing, _ := group["outbounds"].([]interface{})
+		tags := make([]string, 0, len(existing))
+		for _, tag := range existing {
+			if name, ok := tag.(string); ok {
+				tags = append(tags, name)
+			}
+		}
+		for _, tag := range matched {
+			if !util.InSlice(tags, tag) {
+				existing = append(existing, tag)
+				tags = append(tags, tag)
+			}
+		}
+		group["outbounds"] = existing
+	}
+
+	for _, node := range s.nodesData {
+		outbounds = append(outbounds, node)
+	}
+	doc["outbounds"] = outbounds
+
+	output, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
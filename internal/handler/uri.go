@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/exporter"
+
+	"go.uber.org/zap"
+)
+
+// serveURIList 输出分享链接列表，encode 为 true 时整体 base64 编码（v2rayN / Shadowrocket 订阅格式）
+// 节点按用户权限与模板的 node_filter 过滤
+func (h *Handler) serveURIList(w http.ResponseWriter, r *http.Request, p principal, templateName string, encode bool) {
+	format := "uri"
+	if encode {
+		format = "base64"
+	}
+	snap := h.loadSnapshot()
+	key := newRenderKey(p, templateName, format, "")
+	if entry, ok := snap.renders.get(key); ok {
+		h.writeRender(w, r, entry)
+		h.logger.Info("Served cached share links",
+			zap.String("remote_addr", h.clientIP(r)),
+			zap.String("user", p.name()),
+			zap.String("template", templateName),
+			zap.Bool("base64", encode),
+		)
+		return
+	}
+
+	hidden := snap.hiddenNames(p, templateName, snap.nodesName)
+	visible := make([]map[string]interface{}, 0, len(snap.nodesData))
+	for _, node := range snap.nodesData {
+		if tag, _ := node["tag"].(string); !hidden[tag] {
+			visible = append(visible, node)
+		}
+	}
+
+	links, warnings := exporter.ToURIs(visible)
+
+	for _, warning := range warnings {
+		h.logger.Debug("Node skipped for uri output",
+			zap.String("reason", warning),
+		)
+	}
+
+	output := strings.Join(links, "\n")
+	if encode {
+		output = base64.StdEncoding.EncodeToString([]byte(output))
+	}
+
+	entry := newRenderEntry([]byte(output), "text/plain; charset=utf-8", len(links))
+	snap.renders.put(key, entry)
+	h.writeRender(w, r, entry)
+
+	h.logger.Info("Successfully served share links",
+		zap.String("remote_addr", h.clientIP(r)),
+		zap.String("user", p.name()),
+		zap.String("template", templateName),
+		zap.Bool("base64", encode),
+		zap.Int("node_count", len(links)),
+		zap.Int("skipped", len(warnings)),
+	)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/haierkeys/singbox-subscribe-convert/internal/validator"
+
+	"go.uber.org/zap"
+)
+
+// maxLastGood 保留的上次通过校验结果的数量上限
+const maxLastGood = 256
+
+// renderError 渲染或校验失败时返回的结构化错误
+type renderError struct {
+	Status   string            `json:"status"`
+	Template string            `json:"template"`
+	Error    string            `json:"error"`
+	Issues   []validator.Issue `json:"issues,omitempty"`
+}
+
+// lastGoodKey 上次成功渲染结果的缓存键
+func lastGoodKey(p principal, templateName, setType string) string {
+	return p.name() + "\x00" + templateName + "\x00" + setType
+}
+
+// validateOutput 按配置校验渲染结果，未知字段等警告只记录日志
+func (h *Handler) validateOutput(templateName, output, targetVersion string) error {
+	if !h.cfg.Validation.IsEnabled() {
+		return nil
+	}
+	warnings, err := validator.Validate([]byte(output), targetVersion, h.cfg.Validation.Strict)
+	if len(warnings) > 0 {
+		h.logger.Warn("Rendered config has unknown fields",
+			zap.String("template", templateName),
+			zap.Any("warnings", warnings),
+		)
+	}
+	return err
+}
+
+// storeLastGood 记录通过校验的渲染结果
+func (h *Handler) storeLastGood(p principal, templateName, setType, output string) {
+	h.lastGoodRenders.put(lastGoodKey(p, templateName, setType), output)
+}
+
+// loadLastGood 读取上次通过校验的渲染结果
+func (h *Handler) loadLastGood(p principal, templateName, setType string) (string, bool) {
+	return h.lastGoodRenders.get(lastGoodKey(p, templateName, setType))
+}
+
+// writeRenderError 输出渲染失败的结构化错误
+func writeRenderError(w http.ResponseWriter, templateName string, err error) {
+	resp := renderError{
+		Status:   "error",
+		Template: templateName,
+		Error:    err.Error(),
+	}
+	var validationErr *validator.Error
+	if errors.As(err, &validationErr) {
+		resp.Error = "rendered config failed validation"
+		resp.Issues = validationErr.Issues
+	}
+
+	data, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(data)
+}
+
+// handleRenderFailure 处理渲染或校验失败：按配置返回上次通过校验的结果，否则返回结构化错误
+func (h *Handler) handleRenderFailure(w http.ResponseWriter, r *http.Request, p principal, templateName, setType string, nodeCount int, err error) {
+	h.logger.Error("Error rendering template",
+		zap.Error(err),
+		zap.String("template", templateName),
+	)
+
+	if h.cfg.Validation.ServeLastGood() {
+		if output, ok := h.loadLastGood(p, templateName, setType); ok {
+			h.logger.Warn("Serving last known-good config",
+				zap.String("remote_addr", h.clientIP(r)),
+				zap.String("template", templateName),
+				zap.String("type", setType),
+			)
+			w.Header().Set("X-Config-Stale", "1")
+			h.writeRender(w, r, newRenderEntry([]byte(output), "application/json", nodeCount))
+			return
+		}
+	}
+
+	writeRenderError(w, templateName, err)
+}
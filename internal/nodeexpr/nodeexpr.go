@@ -0,0 +1,382 @@
+// Package nodeexpr 节点筛选表达式，供 NotesName 等模板过滤器、结构化模板 $filter、用户 filter 与 node_filter 共用
+//
+// 语法（优先级由低到高）：
+//
+//	expr    = and { "|" and }           任一满足
+//	and     = unary { "&" unary }       同时满足
+//	unary   = "!" unary | "(" expr ")" | term
+//	term    = "re:" 正则 | "i:" 文本 | 字段 "=" 值 | 文本
+//
+// 文本为名称包含匹配（区分大小写），i: 不区分大小写，re: 为正则匹配；
+// 字段支持 type、region、source、port、name，值不区分大小写（name 为完整名称匹配）。
+// 文本与值可用单引号或双引号包围以包含 | & ( ) 等字符；表达式为空时匹配全部节点。
+package nodeexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Node 参与筛选的节点属性
+type Node struct {
+	Name   string
+	Type   string
+	Region string
+	Source string
+	Port   string
+}
+
+// Expr 解析后的筛选表达式
+type Expr struct {
+	root node
+}
+
+// node 表达式语法树节点
+type node interface {
+	match(n Node) bool
+}
+
+type orNode []node
+type andNode []node
+type notNode struct{ inner node }
+type containsNode string
+type foldNode string
+type regexNode struct{ re *regexp.Regexp }
+type fieldNode struct{ field, value string }
+
+func (o orNode) match(n Node) bool {
+	for _, child := range o {
+		if child.match(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a andNode) match(n Node) bool {
+	for _, child := range a {
+		if !child.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (x notNode) match(n Node) bool      { return !x.inner.match(n) }
+func (c containsNode) match(n Node) bool { return strings.Contains(n.Name, string(c)) }
+func (f foldNode) match(n Node) bool {
+	return strings.Contains(strings.ToLower(n.Name), string(f))
+}
+func (r regexNode) match(n Node) bool { return r.re.MatchString(n.Name) }
+
+func (f fieldNode) match(n Node) bool {
+	var actual string
+	switch f.field {
+	case "type":
+		actual = n.Type
+	case "region":
+		actual = n.Region
+	case "source":
+		actual = n.Source
+	case "port":
+		actual = n.Port
+	case "name":
+		actual = n.Name
+	}
+	return strings.EqualFold(actual, f.value)
+}
+
+// fields 支持的字段名称
+var fields = map[string]bool{"type": true, "region": true, "source": true, "port": true, "name": true}
+
+// Parse 解析筛选表达式
+func Parse(expr string) (*Expr, error) {
+	p := &parser{input: expr}
+	p.skipSpace()
+	if p.done() {
+		return &Expr{}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+		return nil, p.errorf("unexpected %q", r)
+	}
+	return &Expr{root: root}, nil
+}
+
+// Validate 校验表达式语法
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+// Match 判断节点是否满足表达式，空表达式匹配全部节点
+func (e *Expr) Match(n Node) bool {
+	return e == nil || e.root == nil || e.root.match(n)
+}
+
+// SyntaxError 表达式语法错误
+type SyntaxError struct {
+	Expr string
+	Pos  int // 出错位置（按字符计）
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter expression %q at position %d: %s", e.Expr, e.Pos+1, e.Msg)
+}
+
+// parser 递归下降解析器
+type parser struct {
+	input string
+	pos   int // 字节偏移
+	depth int // 括号层数
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Expr: p.input,
+		Pos:  utf8.RuneCountInString(p.input[:p.pos]),
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.input) }
+
+func (p *parser) peek() byte { return p.input[p.pos] }
+
+func (p *parser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// parseOr 解析 | 连接的表达式，为兼容旧写法，空的分支（如 "香港|"）会被忽略
+func (p *parser) parseOr() (node, error) {
+	var children orNode
+	for {
+		p.skipSpace()
+		if !p.done() && p.peek() != '|' && !(p.peek() == ')' && p.depth > 0) {
+			child, err := p.parseAnd()
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
+		p.skipSpace()
+		if p.done() || p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+
+	switch len(children) {
+	case 0:
+		return nil, p.errorf("empty expression")
+	case 1:
+		return children[0], nil
+	}
+	return children, nil
+}
+
+// parseAnd 解析 & 连接的表达式
+func (p *parser) parseAnd() (node, error) {
+	var children andNode
+	for {
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+
+		p.skipSpace()
+		if p.done() || p.peek() != '&' {
+			break
+		}
+		p.pos++
+	}
+
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return children, nil
+}
+
+// parseUnary 解析取反、括号与单个条件
+func (p *parser) parseUnary() (node, error) {
+	p.skipSpace()
+	if p.done() {
+		return nil, p.errorf("missing operand")
+	}
+
+	switch p.peek() {
+	case '!':
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	case '(':
+		start := p.pos
+		p.pos++
+		p.depth++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.done() || p.peek() != ')' {
+			p.pos = start
+			return nil, p.errorf("missing ')'")
+		}
+		p.pos++
+		p.depth--
+		return inner, nil
+	case '|', '&', ')':
+		return nil, p.errorf("missing operand before %q", p.peek())
+	}
+	return p.parseTerm()
+}
+
+// parseTerm 解析 re:、i:、字段与文本条件
+func (p *parser) parseTerm() (node, error) {
+	rest := p.input[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "re:"):
+		p.pos += len("re:")
+		start := p.pos
+		pattern, err := p.readRegex()
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid regex: %v", err)
+		}
+		return regexNode{re: re}, nil
+
+	case strings.HasPrefix(rest, "i:"):
+		p.pos += len("i:")
+		text, err := p.readText()
+		if err != nil {
+			return nil, err
+		}
+		return foldNode(strings.ToLower(text)), nil
+	}
+
+	if name, _, ok := strings.Cut(rest, "="); ok && fields[strings.TrimSpace(name)] {
+		p.pos += len(name) + 1
+		value, err := p.readText()
+		if err != nil {
+			return nil, err
+		}
+		return fieldNode{field: strings.TrimSpace(name), value: value}, nil
+	}
+
+	text, err := p.readText()
+	if err != nil {
+		return nil, err
+	}
+	return containsNode(text), nil
+}
+
+// readText 读取文本，遇到 | & 或所在括号的 ) 结束；可用引号包围以包含这些字符
+func (p *parser) readText() (string, error) {
+	p.skipSpace()
+	if !p.done() && (p.peek() == '"' || p.peek() == '\'') {
+		return p.readQuoted()
+	}
+
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		if c == '|' || c == '&' || (c == ')' && p.depth > 0) {
+			break
+		}
+		p.pos++
+	}
+	text := strings.TrimSpace(p.input[start:p.pos])
+	if text == "" {
+		return "", p.errorf("missing value")
+	}
+	return text, nil
+}
+
+// readQuoted 读取引号包围的文本，支持 \ 转义
+func (p *parser) readQuoted() (string, error) {
+	quote := p.peek()
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for !p.done() {
+		c := p.peek()
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			b.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		case c == quote:
+			p.pos++
+			if b.Len() == 0 {
+				p.pos = start
+				return "", p.errorf("empty quoted value")
+			}
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated quote")
+}
+
+// readRegex 读取正则，正则内部括号与字符类中的 | & ) 不结束条件
+func (p *parser) readRegex() (string, error) {
+	p.skipSpace()
+	if !p.done() && (p.peek() == '"' || p.peek() == '\'') {
+		return p.readQuoted()
+	}
+
+	start := p.pos
+	depth, inClass := 0, false
+	for !p.done() {
+		c := p.peek()
+		if c == '\\' {
+			p.pos += 2
+			continue
+		}
+		switch {
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0 && (c == '|' || c == '&' || (c == ')' && p.depth > 0)):
+			goto end
+		}
+		p.pos++
+	}
+end:
+	if p.pos > len(p.input) {
+		p.pos = len(p.input)
+	}
+	pattern := strings.TrimSpace(p.input[start:p.pos])
+	if pattern == "" {
+		return "", p.errorf("missing regex")
+	}
+	return pattern, nil
+}
@@ -0,0 +1,139 @@
+package nodeexpr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testNodes = []Node{
+	{Name: "🇭🇰 香港 IPLC 01", Type: "vmess", Region: "HK", Source: "a", Port: "443"},
+	{Name: "HK 02 测试", Type: "hysteria2", Region: "HK", Source: "a", Port: "8443"},
+	{Name: "hk-lite", Type: "trojan", Region: "HK", Source: "b", Port: "443"},
+	{Name: "日本 01", Type: "hysteria2", Region: "JP", Source: "b", Port: "443"},
+	{Name: "US | 1.5x", Type: "vless", Region: "US", Source: "c", Port: "10086"},
+	{Name: "IPLC(专线) & SG", Type: "shadowsocks", Region: "SG", Source: "c", Port: "8388"},
+}
+
+func matchNames(t *testing.T, expr string) []string {
+	t.Helper()
+	e, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", expr, err)
+	}
+	matched := []string{}
+	for _, n := range testNodes {
+		if e.Match(n) {
+			matched = append(matched, n.Name)
+		}
+	}
+	return matched
+}
+
+func TestMatch(t *testing.T) {
+	all := []string{"🇭🇰 香港 IPLC 01", "HK 02 测试", "hk-lite", "日本 01", "US | 1.5x", "IPLC(专线) & SG"}
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"empty matches all", "", all},
+		{"blank matches all", "   ", all},
+		{"substring", "香港", []string{"🇭🇰 香港 IPLC 01"}},
+		{"substring is case sensitive", "hk", []string{"hk-lite"}},
+		{"legacy or", "香港|日本", []string{"🇭🇰 香港 IPLC 01", "日本 01"}},
+		{"legacy trailing empty branch", "香港|", []string{"🇭🇰 香港 IPLC 01"}},
+		{"legacy spaces around keywords", " 香港 | 日本 ", []string{"🇭🇰 香港 IPLC 01", "日本 01"}},
+		{"case insensitive", "i:hk", []string{"HK 02 测试", "hk-lite"}},
+		{"and", "香港 & IPLC", []string{"🇭🇰 香港 IPLC 01"}},
+		{"not", "!测试 & i:hk", []string{"hk-lite"}},
+		{"double not", "!!日本", []string{"日本 01"}},
+		{"and binds tighter than or", "日本 | i:hk & !测试", []string{"hk-lite", "日本 01"}},
+		{"parentheses override precedence", "(日本 | i:hk) & !测试", []string{"hk-lite", "日本 01"}},
+		{"not applies to group", "!(i:hk | 日本)", []string{"🇭🇰 香港 IPLC 01", "US | 1.5x", "IPLC(专线) & SG"}},
+		{"regex", "re:^HK", []string{"HK 02 测试"}},
+		{"regex or inside group", "re:^(HK|🇭🇰)", []string{"🇭🇰 香港 IPLC 01", "HK 02 测试"}},
+		{"regex or at top level splits", "re:^HK|日本", []string{"HK 02 测试", "日本 01"}},
+		{"regex character class", "re:^[h]k-", []string{"hk-lite"}},
+		{"regex escaped paren", `re:\(专线\)`, []string{"IPLC(专线) & SG"}},
+		{"regex inside group", "(re:^(HK|日本) | 美国) & !测试", []string{"日本 01"}},
+		{"regex flags", "re:(?i)^hk", []string{"HK 02 测试", "hk-lite"}},
+		{"quoted regex", `re:"1\.5x$"`, []string{"US | 1.5x"}},
+		{"double quoted text", `"US | 1.5x"`, []string{"US | 1.5x"}},
+		{"single quoted text", `'IPLC(专线) & SG'`, []string{"IPLC(专线) & SG"}},
+		{"quoted with escape", `'US \| 1'`, []string{"US | 1.5x"}},
+		{"quoted case insensitive", `i:'us | '`, []string{"US | 1.5x"}},
+		{"paren inside text is literal", "IPLC(专线)", []string{"IPLC(专线) & SG"}},
+		{"type field", "type=hysteria2", []string{"HK 02 测试", "日本 01"}},
+		{"field value case insensitive", "region=jp", []string{"日本 01"}},
+		{"field with spaces", " type = trojan ", []string{"hk-lite"}},
+		{"source field", "source=c", []string{"US | 1.5x", "IPLC(专线) & SG"}},
+		{"port field", "port=443 & !region=HK", []string{"日本 01"}},
+		{"name field is exact", "name=hk-lite", []string{"hk-lite"}},
+		{"name field does not match substring", "name=hk", []string{}},
+		{"negated field", "region=HK & !type=vmess", []string{"HK 02 测试", "hk-lite"}},
+		{"unknown field is text", "foo=bar", []string{}},
+		{"stray close paren at top level is text", "香港)", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchNames(t, tt.expr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%q matched %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"a &", 3, "missing operand"},
+		{"& a", 0, `missing operand before '&'`},
+		{"a & | b", 4, `missing operand before '|'`},
+		{"!", 1, "missing operand"},
+		{"(香港", 0, "missing ')'"},
+		{"(香港 | (日本)", 0, "missing ')'"},
+		{"()", 1, "empty expression"},
+		{"re:", 3, "missing regex"},
+		{"香港 & re:[", 8, "invalid regex: error parsing regexp: missing closing ]: `[`"},
+		{"i:", 2, "missing value"},
+		{"type=", 5, "missing value"},
+		{"'abc", 0, "unterminated quote"},
+		{`i:""`, 2, "empty quoted value"},
+		{"'a' b", 4, "unexpected 'b'"},
+		{"'香港' 日本", 5, "unexpected '日'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want *SyntaxError", tt.expr, err)
+			}
+			if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+				t.Errorf("Parse(%q) error at %d %q, want at %d %q", tt.expr, syntaxErr.Pos, syntaxErr.Msg, tt.pos, tt.msg)
+			}
+			if err := Validate(tt.expr); err == nil {
+				t.Errorf("Validate(%q) = nil, want error", tt.expr)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorMessage(t *testing.T) {
+	err := Validate("(香港")
+	want := `invalid filter expression "(香港" at position 1: missing ')'`
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate error = %v, want %s", err, want)
+	}
+}
+
+func TestNilExprMatchesAll(t *testing.T) {
+	var e *Expr
+	if !e.Match(Node{Name: "x"}) {
+		t.Error("nil Expr should match every node")
+	}
+}
@@ -0,0 +1,233 @@
+// Package nodefilter 按 include / exclude 规则筛选节点
+package nodefilter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/nodeexpr"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/region"
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+)
+
+// rule 编译后的过滤规则
+type rule struct {
+	label    string // 规则标识，如 exclude[0]
+	pattern  *regexp.Regexp
+	keywords []string
+	types    []string
+	networks []*net.IPNet
+	ports    []global.PortRange
+	expr     *nodeexpr.Expr
+	exprText string
+}
+
+// Filter 编译后的节点过滤规则
+type Filter struct {
+	include []rule
+	exclude []rule
+}
+
+// Compile 编译过滤规则
+func Compile(f global.NodeFilter) (*Filter, error) {
+	include, err := compileRules("include", f.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileRules("exclude", f.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{include: include, exclude: exclude}, nil
+}
+
+func compileRules(kind string, rules []global.FilterRule) ([]rule, error) {
+	compiled := make([]rule, 0, len(rules))
+	for i, r := range rules {
+		c := rule{
+			label:    fmt.Sprintf("%s[%d]", kind, i),
+			keywords: r.Keywords,
+			types:    r.Types,
+		}
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s invalid pattern: %w", c.label, err)
+			}
+			c.pattern = re
+		}
+		for _, cidr := range r.CIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("%s invalid cidr: %s", c.label, cidr)
+			}
+			c.networks = append(c.networks, network)
+		}
+		ports, err := global.ParsePortRanges(r.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", c.label, err)
+		}
+		c.ports = ports
+		if r.Expr != "" {
+			expr, err := nodeexpr.Parse(r.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s expr: %w", c.label, err)
+			}
+			c.expr, c.exprText = expr, r.Expr
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// IsEmpty 是否未设置任何规则
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.include) == 0 && len(f.exclude) == 0)
+}
+
+// Match 判断节点是否保留，不保留时返回原因；source 为节点所属订阅源，供 expr 中的 source 字段使用
+func (f *Filter) Match(node map[string]interface{}, source string) (bool, string) {
+	if f.IsEmpty() {
+		return true, ""
+	}
+
+	if len(f.include) > 0 {
+		included := false
+		for _, r := range f.include {
+			if ok, _ := r.match(node, source); ok {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false, "not matched by any include rule"
+		}
+	}
+
+	for _, r := range f.exclude {
+		if ok, reason := r.match(node, source); ok {
+			return false, r.label + " " + reason
+		}
+	}
+	return true, ""
+}
+
+// match 判断节点是否满足规则的全部条件，返回命中的条件说明
+func (r rule) match(node map[string]interface{}, source string) (bool, string) {
+	name, _ := node["tag"].(string)
+	var reasons []string
+
+	if r.pattern != nil {
+		if !r.pattern.MatchString(name) {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("pattern %q", r.pattern.String()))
+	}
+
+	if len(r.keywords) > 0 {
+		keyword, ok := matchKeyword(name, r.keywords)
+		if !ok {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("keyword %q", keyword))
+	}
+
+	if len(r.types) > 0 {
+		outboundType, _ := node["type"].(string)
+		if !util.InSlice(r.types, outboundType) {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("type %q", outboundType))
+	}
+
+	if len(r.networks) > 0 {
+		server, _ := node["server"].(string)
+		network, ok := matchNetwork(server, r.networks)
+		if !ok {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("server %s in %s", server, network))
+	}
+
+	if len(r.ports) > 0 {
+		port, ok := serverPort(node)
+		if !ok || !matchPort(port, r.ports) {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("port %d", port))
+	}
+
+	if r.expr != nil {
+		server, _ := node["server"].(string)
+		if !r.expr.Match(ExprNode(node, source, region.Classify(name, server))) {
+			return false, ""
+		}
+		reasons = append(reasons, fmt.Sprintf("expr %q", r.exprText))
+	}
+
+	return true, strings.Join(reasons, ", ")
+}
+
+// matchKeyword 名称包含任一关键词时返回该关键词
+func matchKeyword(name string, keywords []string) (string, bool) {
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(name, keyword) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// matchNetwork server 为 IP 且位于任一网段时返回该网段
+func matchNetwork(server string, networks []*net.IPNet) (*net.IPNet, bool) {
+	ip := net.ParseIP(strings.Trim(server, "[]"))
+	if ip == nil {
+		return nil, false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return network, true
+		}
+	}
+	return nil, false
+}
+
+// ExprNode 将 outbound 转换为筛选表达式使用的节点属性
+func ExprNode(node map[string]interface{}, source, regionCode string) nodeexpr.Node {
+	name, _ := node["tag"].(string)
+	outboundType, _ := node["type"].(string)
+	n := nodeexpr.Node{Name: name, Type: outboundType, Region: regionCode, Source: source}
+	if port, ok := serverPort(node); ok {
+		n.Port = strconv.Itoa(port)
+	}
+	return n
+}
+
+// serverPort 读取节点的 server_port，兼容 JSON 解析得到的浮点数与字符串
+func serverPort(node map[string]interface{}) (int, bool) {
+	switch v := node["server_port"].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case string:
+		port, err := strconv.Atoi(v)
+		return port, err == nil
+	}
+	return 0, false
+}
+
+func matchPort(port int, ranges []global.PortRange) bool {
+	for _, r := range ranges {
+		if port >= r.From && port <= r.To {
+			return true
+		}
+	}
+	return false
+}
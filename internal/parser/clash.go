@@ -0,0 +1,493 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clashFile Clash / Clash.Meta 配置文件中与节点相关的部分
+type clashFile struct {
+	Proxies []map[string]interface{} `yaml:"proxies"`
+}
+
+// clashConverter 单个 Clash 节点类型的转换函数
+type clashConverter func(p *clashProxy) (map[string]interface{}, error)
+
+// clashConverters Clash 节点 type -> 转换函数
+var clashConverters = map[string]clashConverter{
+	"ss":        convertClashShadowsocks,
+	"vmess":     convertClashVmess,
+	"vless":     convertClashVless,
+	"trojan":    convertClashTrojan,
+	"hysteria2": convertClashHysteria2,
+	"tuic":      convertClashTuic,
+	"wireguard": convertClashWireguard,
+}
+
+// clashIgnoredFields 对 sing-box 没有意义、无需提示的字段
+var clashIgnoredFields = map[string]bool{
+	"udp": true,
+}
+
+// isClashYAML 判断内容是否为包含 proxies 列表的 Clash YAML
+func isClashYAML(data []byte) bool {
+	var file clashFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return false
+	}
+	return len(file.Proxies) > 0
+}
+
+// parseClash 解析 Clash YAML 中的 proxies 列表
+func parseClash(data []byte) (*Result, error) {
+	var file clashFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse clash yaml error: %w", err)
+	}
+
+	result := &Result{Format: FormatClash}
+
+	for i, raw := range file.Proxies {
+		p := newClashProxy(raw)
+		name := p.str("name")
+		if name == "" {
+			name = fmt.Sprintf("proxies[%d]", i)
+		}
+
+		outbound, err := convertClashProxy(p)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		if unused := p.unused(); len(unused) > 0 {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("%s: unsupported fields ignored: %s", name, strings.Join(unused, ", ")))
+		}
+		result.Outbounds = append(result.Outbounds, outbound)
+	}
+
+	if len(result.Outbounds) == 0 {
+		return nil, fmt.Errorf("no valid clash proxies found (%d skipped)", len(result.Warnings))
+	}
+
+	return result, nil
+}
+
+// convertClashProxy 将单个 Clash 节点转换为 sing-box outbound
+func convertClashProxy(p *clashProxy) (map[string]interface{}, error) {
+	proxyType := p.str("type")
+	convert, ok := clashConverters[proxyType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported type: %s", proxyType)
+	}
+
+	server := p.str("server")
+	if server == "" {
+		return nil, fmt.Errorf("missing server")
+	}
+	port, err := parsePort(p.str("port"))
+	if err != nil {
+		return nil, err
+	}
+
+	outbound, err := convert(p)
+	if err != nil {
+		return nil, err
+	}
+
+	outbound["tag"] = nodeTag(p.str("name"), server, port)
+	outbound["server"] = server
+	outbound["server_port"] = port
+
+	if p.bool("tfo") {
+		outbound["tcp_fast_open"] = true
+	}
+	if p.bool("mptcp") {
+		outbound["tcp_multi_path"] = true
+	}
+
+	return outbound, nil
+}
+
+// convertClashShadowsocks 转换 ss 节点
+func convertClashShadowsocks(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":     "shadowsocks",
+		"method":   p.str("cipher"),
+		"password": p.str("password"),
+	}
+
+	if p.bool("udp-over-tcp") {
+		outbound["udp_over_tcp"] = true
+	}
+
+	switch plugin := p.str("plugin"); plugin {
+	case "":
+	case "obfs":
+		opts := p.sub("plugin-opts")
+		pluginOpts := []string{"obfs=" + opts.str("mode")}
+		if host := opts.str("host"); host != "" {
+			pluginOpts = append(pluginOpts, "obfs-host="+host)
+		}
+		outbound["plugin"] = "obfs-local"
+		outbound["plugin_opts"] = strings.Join(pluginOpts, ";")
+	case "v2ray-plugin":
+		opts := p.sub("plugin-opts")
+		pluginOpts := []string{"mode=" + firstNonEmpty(opts.str("mode"), "websocket")}
+		if opts.bool("tls") {
+			pluginOpts = append(pluginOpts, "tls")
+		}
+		if host := opts.str("host"); host != "" {
+			pluginOpts = append(pluginOpts, "host="+host)
+		}
+		if path := opts.str("path"); path != "" {
+			pluginOpts = append(pluginOpts, "path="+path)
+		}
+		outbound["plugin"] = "v2ray-plugin"
+		outbound["plugin_opts"] = strings.Join(pluginOpts, ";")
+	default:
+		return nil, fmt.Errorf("unsupported ss plugin: %s", plugin)
+	}
+
+	return outbound, nil
+}
+
+// convertClashVmess 转换 vmess 节点
+func convertClashVmess(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":     "vmess",
+		"uuid":     p.str("uuid"),
+		"alter_id": p.int("alterId"),
+		"security": firstNonEmpty(p.str("cipher"), "auto"),
+	}
+
+	if p.bool("tls") {
+		outbound["tls"] = clashTLS(p, "servername").build()
+	}
+	if err := applyClashTransport(outbound, p); err != nil {
+		return nil, err
+	}
+	return outbound, nil
+}
+
+// convertClashVless 转换 vless 节点
+func convertClashVless(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type": "vless",
+		"uuid": p.str("uuid"),
+	}
+
+	if flow := p.str("flow"); flow != "" {
+		outbound["flow"] = flow
+	}
+	if encoding := p.str("packet-encoding"); encoding != "" {
+		outbound["packet_encoding"] = encoding
+	}
+
+	if p.bool("tls") {
+		outbound["tls"] = clashTLS(p, "servername").build()
+	}
+	if err := applyClashTransport(outbound, p); err != nil {
+		return nil, err
+	}
+	return outbound, nil
+}
+
+// convertClashTrojan 转换 trojan 节点
+func convertClashTrojan(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":     "trojan",
+		"password": p.str("password"),
+	}
+
+	tls := clashTLS(p, "sni")
+	if tls.ServerName == "" {
+		tls.ServerName = p.str("server")
+	}
+	outbound["tls"] = tls.build()
+
+	if err := applyClashTransport(outbound, p); err != nil {
+		return nil, err
+	}
+	return outbound, nil
+}
+
+// convertClashHysteria2 转换 hysteria2 节点
+func convertClashHysteria2(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":     "hysteria2",
+		"password": p.str("password"),
+	}
+
+	if ports := p.str("ports"); ports != "" {
+		var serverPorts []string
+		for _, r := range splitList(ports) {
+			serverPorts = append(serverPorts, strings.Replace(r, "-", ":", 1))
+		}
+		outbound["server_ports"] = serverPorts
+	}
+
+	if obfs := p.str("obfs"); obfs != "" {
+		if obfs != "salamander" {
+			return nil, fmt.Errorf("unsupported obfs: %s", obfs)
+		}
+		outbound["obfs"] = map[string]interface{}{
+			"type":     "salamander",
+			"password": p.str("obfs-password"),
+		}
+	}
+
+	if up := p.int("up"); up > 0 {
+		outbound["up_mbps"] = up
+	}
+	if down := p.int("down"); down > 0 {
+		outbound["down_mbps"] = down
+	}
+
+	tls := clashTLS(p, "sni")
+	if tls.ServerName == "" {
+		tls.ServerName = p.str("server")
+	}
+	outbound["tls"] = tls.build()
+	return outbound, nil
+}
+
+// convertClashTuic 转换 tuic 节点
+func convertClashTuic(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":     "tuic",
+		"uuid":     p.str("uuid"),
+		"password": p.str("password"),
+	}
+
+	if cc := p.str("congestion-controller"); cc != "" {
+		outbound["congestion_control"] = cc
+	}
+	if mode := p.str("udp-relay-mode"); mode != "" {
+		outbound["udp_relay_mode"] = mode
+	}
+	if p.bool("reduce-rtt") {
+		outbound["zero_rtt_handshake"] = true
+	}
+
+	tls := clashTLS(p, "sni")
+	if tls.ServerName == "" {
+		tls.ServerName = p.str("server")
+	}
+	if p.bool("disable-sni") {
+		tls.ServerName = ""
+	}
+	outbound["tls"] = tls.build()
+	return outbound, nil
+}
+
+// convertClashWireguard 转换 wireguard 节点
+func convertClashWireguard(p *clashProxy) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":            "wireguard",
+		"private_key":     p.str("private-key"),
+		"peer_public_key": p.str("public-key"),
+	}
+
+	var localAddress []string
+	if ip := p.str("ip"); ip != "" {
+		if !strings.Contains(ip, "/") {
+			ip += "/32"
+		}
+		localAddress = append(localAddress, ip)
+	}
+	if ipv6 := p.str("ipv6"); ipv6 != "" {
+		if !strings.Contains(ipv6, "/") {
+			ipv6 += "/128"
+		}
+		localAddress = append(localAddress, ipv6)
+	}
+	if len(localAddress) == 0 {
+		return nil, fmt.Errorf("missing ip")
+	}
+	outbound["local_address"] = localAddress
+
+	if psk := firstNonEmpty(p.str("pre-shared-key"), p.str("preshared-key")); psk != "" {
+		outbound["pre_shared_key"] = psk
+	}
+	if reserved := p.intList("reserved"); len(reserved) > 0 {
+		outbound["reserved"] = reserved
+	}
+	if mtu := p.int("mtu"); mtu > 0 {
+		outbound["mtu"] = mtu
+	}
+	// sing-box 的 wireguard 出站固定转发全部流量
+	p.strList("allowed-ips")
+
+	return outbound, nil
+}
+
+// clashTLS 读取 Clash 节点的 TLS 相关字段，sniKey 为该类型使用的 SNI 字段名
+func clashTLS(p *clashProxy, sniKey string) tlsOptions {
+	opts := tlsOptions{
+		ServerName:  p.str(sniKey),
+		Insecure:    p.bool("skip-cert-verify"),
+		ALPN:        p.strList("alpn"),
+		Fingerprint: p.str("client-fingerprint"),
+	}
+	if p.has("reality-opts") {
+		reality := p.sub("reality-opts")
+		opts.Reality = true
+		opts.PublicKey = reality.str("public-key")
+		opts.ShortID = reality.str("short-id")
+	}
+	return opts
+}
+
+// applyClashTransport 读取 Clash 节点的 network 及对应 *-opts 字段
+func applyClashTransport(outbound map[string]interface{}, p *clashProxy) error {
+	opts := transportOptions{Type: p.str("network")}
+
+	switch opts.Type {
+	case "ws":
+		ws := p.sub("ws-opts")
+		opts.Path = ws.str("path")
+		opts.Host = ws.sub("headers").str("Host")
+		if ws.bool("v2ray-http-upgrade") {
+			opts.Type = "httpupgrade"
+			break
+		}
+		if err := applyTransport(outbound, opts); err != nil {
+			return err
+		}
+		if transport, ok := outbound["transport"].(map[string]interface{}); ok {
+			if ed := ws.int("max-early-data"); ed > 0 {
+				transport["max_early_data"] = ed
+				transport["early_data_header_name"] = firstNonEmpty(ws.str("early-data-header-name"), "Sec-WebSocket-Protocol")
+			}
+		}
+		return nil
+	case "grpc":
+		opts.ServiceName = p.sub("grpc-opts").str("grpc-service-name")
+	case "h2":
+		h2 := p.sub("h2-opts")
+		opts.Host = strings.Join(h2.strList("host"), ",")
+		opts.Path = h2.str("path")
+	case "http":
+		httpOpts := p.sub("http-opts")
+		opts.Type = "tcp"
+		opts.HeaderType = "http"
+		opts.Path = strings.Join(httpOpts.strList("path"), ",")
+		opts.Host = strings.Join(httpOpts.sub("headers").strList("Host"), ",")
+	}
+
+	return applyTransport(outbound, opts)
+}
+
+// clashProxy 记录已读取字段的 Clash 节点，用于报告未支持的字段
+type clashProxy struct {
+	raw  map[string]interface{}
+	used map[string]bool
+}
+
+// newClashProxy 创建 clashProxy
+func newClashProxy(raw map[string]interface{}) *clashProxy {
+	return &clashProxy{raw: raw, used: make(map[string]bool)}
+}
+
+// get 读取字段并标记为已使用
+func (p *clashProxy) get(key string) interface{} {
+	p.used[key] = true
+	return p.raw[key]
+}
+
+// has 判断字段是否存在
+func (p *clashProxy) has(key string) bool {
+	_, ok := p.raw[key]
+	return ok
+}
+
+// str 读取字符串字段
+func (p *clashProxy) str(key string) string {
+	return anyToString(p.get(key))
+}
+
+// int 读取整数字段
+func (p *clashProxy) int(key string) int {
+	switch v := p.get(key).(type) {
+	case int:
+		return v
+	case string:
+		// 兼容 "100 Mbps" 这类带单位的写法
+		i, _ := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.ToLower(v), "mbps")))
+		return i
+	default:
+		return anyToInt(v)
+	}
+}
+
+// bool 读取布尔字段
+func (p *clashProxy) bool(key string) bool {
+	switch v := p.get(key).(type) {
+	case bool:
+		return v
+	default:
+		return parseBool(anyToString(v))
+	}
+}
+
+// strList 读取字符串列表字段，兼容逗号分隔的字符串
+func (p *clashProxy) strList(key string) []string {
+	switch v := p.get(key).(type) {
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, anyToString(item))
+		}
+		return list
+	case string:
+		return splitList(v)
+	default:
+		return nil
+	}
+}
+
+// intList 读取整数列表字段
+func (p *clashProxy) intList(key string) []int {
+	switch v := p.get(key).(type) {
+	case []interface{}:
+		list := make([]int, 0, len(v))
+		for _, item := range v {
+			list = append(list, anyToInt(item))
+		}
+		return list
+	case string:
+		var list []int
+		for _, item := range splitList(v) {
+			list = append(list, anyToInt(item))
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// sub 读取嵌套的选项字段，字段不存在时返回空对象
+func (p *clashProxy) sub(key string) *clashProxy {
+	if m, ok := p.get(key).(map[string]interface{}); ok {
+		return newClashProxy(m)
+	}
+	return newClashProxy(map[string]interface{}{})
+}
+
+// unused 返回未被转换逻辑读取的字段
+func (p *clashProxy) unused() []string {
+	var keys []string
+	for key := range p.raw {
+		if !p.used[key] && !clashIgnoredFields[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseHysteria2 解析 hysteria2:// 或 hy2:// 链接
+func parseHysteria2(link string) (map[string]interface{}, error) {
+	// 端口跳跃写法 server:20000-30000 无法被 url.Parse 识别，先拆出来
+	link, ports := splitHopPorts(link)
+
+	u, port, err := parseStandardURI(link)
+	if err != nil {
+		return nil, err
+	}
+
+	password := u.User.Username()
+	if p, ok := u.User.Password(); ok {
+		password = password + ":" + p
+	}
+
+	q := u.Query()
+	outbound := map[string]interface{}{
+		"type":        "hysteria2",
+		"tag":         nodeTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"password":    password,
+	}
+
+	if mport := firstNonEmpty(q.Get("mport"), ports); mport != "" {
+		var serverPorts []string
+		for _, r := range splitList(mport) {
+			serverPorts = append(serverPorts, strings.Replace(r, "-", ":", 1))
+		}
+		outbound["server_ports"] = serverPorts
+	}
+
+	if obfs := q.Get("obfs"); obfs != "" {
+		if obfs != "salamander" {
+			return nil, fmt.Errorf("unsupported obfs: %s", obfs)
+		}
+		outbound["obfs"] = map[string]interface{}{
+			"type":     "salamander",
+			"password": q.Get("obfs-password"),
+		}
+	}
+
+	if up := q.Get("upmbps"); up != "" {
+		outbound["up_mbps"] = anyToInt(up)
+	}
+	if down := q.Get("downmbps"); down != "" {
+		outbound["down_mbps"] = anyToInt(down)
+	}
+
+	tls := tlsFromQuery(q, "")
+	if tls.ServerName == "" {
+		tls.ServerName = u.Hostname()
+	}
+	outbound["tls"] = tls.build()
+
+	return outbound, nil
+}
+
+// splitHopPorts 拆出链接中的端口跳跃范围，并将首个端口作为 server_port
+func splitHopPorts(link string) (string, string) {
+	start := strings.Index(link, "://") + 3
+	end := len(link)
+	if idx := strings.IndexAny(link[start:], "/?#"); idx >= 0 {
+		end = start + idx
+	}
+
+	authority := link[start:end]
+	hostStart := strings.LastIndex(authority, "@") + 1
+	colon := strings.LastIndex(authority, ":")
+	if colon < hostStart {
+		return link, ""
+	}
+
+	ports := authority[colon+1:]
+	if !strings.ContainsAny(ports, "-,") {
+		return link, ""
+	}
+
+	first, _, _ := strings.Cut(strings.Split(ports, ",")[0], "-")
+	return link[:start] + authority[:colon+1] + first + link[end:], ports
+}
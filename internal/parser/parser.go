@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Format 订阅内容格式
+type Format string
+
+const (
+	FormatSingbox   Format = "singbox"   // sing-box JSON（包含 outbounds 数组）
+	FormatShareLink Format = "sharelink" // 分享链接列表（可为 base64 编码）
+	FormatClash     Format = "clash"     // Clash / Clash.Meta YAML（包含 proxies 列表）
+	FormatUnknown   Format = "unknown"
+)
+
+// Result 解析结果
+type Result struct {
+	Format    Format                   // 识别出的订阅格式
+	Outbounds []map[string]interface{} // 转换后的 sing-box outbounds
+	Warnings  []string                 // 单个节点的解析问题，不影响其他节点
+}
+
+// singboxFile sing-box 节点文件结构
+type singboxFile struct {
+	Outbounds []map[string]interface{} `json:"outbounds"`
+}
+
+// Parse 自动识别订阅格式并解析为 sing-box outbounds
+func Parse(data []byte) (*Result, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty content")
+	}
+
+	var (
+		result *Result
+		err    error
+	)
+
+	switch DetectFormat(data) {
+	case FormatSingbox:
+		return parseSingbox(data)
+	case FormatShareLink:
+		result, err = parseShareLinks(data)
+	case FormatClash:
+		result, err = parseClash(data)
+	default:
+		return nil, fmt.Errorf("unrecognized subscription format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 统一为 JSON 反序列化后的数据类型，与 sing-box JSON 节点保持一致
+	normalized, err := normalize(result.Outbounds)
+	if err != nil {
+		return nil, err
+	}
+	result.Outbounds = normalized
+	return result, nil
+}
+
+// normalize 通过 JSON 序列化往返统一 outbounds 中的数据类型
+func normalize(outbounds []map[string]interface{}) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(outbounds)
+	if err != nil {
+		return nil, fmt.Errorf("marshal outbounds error: %w", err)
+	}
+	var normalized []map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("unmarshal outbounds error: %w", err)
+	}
+	return normalized, nil
+}
+
+// DetectFormat 识别订阅内容格式
+func DetectFormat(data []byte) Format {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return FormatUnknown
+	}
+
+	if data[0] == '{' {
+		return FormatSingbox
+	}
+
+	if hasShareLink(string(data)) {
+		return FormatShareLink
+	}
+
+	if isClashYAML(data) {
+		return FormatClash
+	}
+
+	// 整体 base64 编码的分享链接列表
+	if decoded, err := decodeBase64(string(data)); err == nil && hasShareLink(decoded) {
+		return FormatShareLink
+	}
+
+	return FormatUnknown
+}
+
+// parseSingbox 解析 sing-box JSON 节点文件
+func parseSingbox(data []byte) (*Result, error) {
+	var file singboxFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse node file error: %w", err)
+	}
+
+	if len(file.Outbounds) == 0 {
+		return nil, fmt.Errorf("no outbounds found in node file")
+	}
+
+	return &Result{
+		Format:    FormatSingbox,
+		Outbounds: file.Outbounds,
+	}, nil
+}
+
+// parseShareLinks 解析分享链接列表
+func parseShareLinks(data []byte) (*Result, error) {
+	content := string(data)
+	if !hasShareLink(content) {
+		decoded, err := decodeBase64(content)
+		if err != nil {
+			return nil, fmt.Errorf("decode base64 subscription error: %w", err)
+		}
+		content = decoded
+	}
+
+	result := &Result{Format: FormatShareLink}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		outbound, err := ParseURI(line)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %v", truncate(line, 64), err))
+			continue
+		}
+		result.Outbounds = append(result.Outbounds, outbound)
+	}
+
+	if len(result.Outbounds) == 0 {
+		return nil, fmt.Errorf("no valid share links found (%d skipped)", len(result.Warnings))
+	}
+
+	return result, nil
+}
+
+// hasShareLink 判断文本中是否包含受支持的分享链接
+func hasShareLink(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if idx := strings.Index(line, "://"); idx > 0 {
+			if _, ok := uriParsers[strings.ToLower(line[:idx])]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truncate 截断过长的字符串，用于日志输出
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max]) + "..."
+}
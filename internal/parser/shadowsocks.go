@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseShadowsocks 解析 ss:// 链接，支持 SIP002 与旧版整体 base64 格式
+func parseShadowsocks(link string) (map[string]interface{}, error) {
+	body := link[strings.Index(link, "://")+3:]
+
+	name := ""
+	if idx := strings.Index(body, "#"); idx >= 0 {
+		name, _ = url.PathUnescape(body[idx+1:])
+		body = body[:idx]
+	}
+
+	// 旧版格式：ss://base64(method:password@server:port)
+	if !strings.Contains(body, "@") {
+		query := ""
+		if idx := strings.Index(body, "?"); idx >= 0 {
+			query = body[idx:]
+			body = body[:idx]
+		}
+		decoded, err := decodeBase64(strings.TrimSuffix(body, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("decode ss payload error: %w", err)
+		}
+		at := strings.LastIndex(decoded, "@")
+		if at < 0 {
+			return nil, fmt.Errorf("invalid ss payload")
+		}
+		userInfo := decoded[:at]
+		body = url.PathEscape(userInfo) + "@" + decoded[at+1:] + query
+	}
+
+	u, port, err := parseStandardURI("ss://" + body)
+	if err != nil {
+		return nil, err
+	}
+
+	method, password, err := parseSSUserInfo(u.User)
+	if err != nil {
+		return nil, err
+	}
+
+	outbound := map[string]interface{}{
+		"type":        "shadowsocks",
+		"tag":         nodeTag(name, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"method":      method,
+		"password":    password,
+	}
+
+	if plugin := u.Query().Get("plugin"); plugin != "" {
+		pluginName, pluginOpts, err := parseSSPlugin(plugin)
+		if err != nil {
+			return nil, err
+		}
+		outbound["plugin"] = pluginName
+		if pluginOpts != "" {
+			outbound["plugin_opts"] = pluginOpts
+		}
+	}
+
+	return outbound, nil
+}
+
+// parseSSUserInfo 解析 method:password，userinfo 可能为 base64 编码或明文
+func parseSSUserInfo(user *url.Userinfo) (string, string, error) {
+	if user == nil {
+		return "", "", fmt.Errorf("missing method and password")
+	}
+
+	// 明文形式（SIP002 中 2022 系列加密方式使用）
+	if password, ok := user.Password(); ok {
+		return user.Username(), password, nil
+	}
+
+	info := user.Username()
+	if decoded, err := decodeBase64(info); err == nil && strings.Contains(decoded, ":") {
+		info = decoded
+	}
+
+	method, password, ok := strings.Cut(info, ":")
+	if !ok || method == "" {
+		return "", "", fmt.Errorf("invalid method and password")
+	}
+	return method, password, nil
+}
+
+// parseSSPlugin 将 SIP003 插件参数转换为 sing-box 的 plugin / plugin_opts
+func parseSSPlugin(plugin string) (string, string, error) {
+	name, opts, _ := strings.Cut(plugin, ";")
+	switch name {
+	case "obfs-local", "simple-obfs":
+		return "obfs-local", opts, nil
+	case "v2ray-plugin":
+		return "v2ray-plugin", opts, nil
+	default:
+		return "", "", fmt.Errorf("unsupported ss plugin: %s", name)
+	}
+}
@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// parseTrojan 解析 trojan://password@server:port?params#name 链接
+func parseTrojan(link string) (map[string]interface{}, error) {
+	u, port, err := parseStandardURI(link)
+	if err != nil {
+		return nil, err
+	}
+
+	password := u.User.Username()
+	if password == "" {
+		return nil, fmt.Errorf("missing password")
+	}
+
+	q := u.Query()
+	outbound := map[string]interface{}{
+		"type":        "trojan",
+		"tag":         nodeTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"password":    password,
+	}
+
+	// trojan 默认启用 TLS，仅在显式声明 security=none 时关闭
+	switch security := q.Get("security"); security {
+	case "none":
+	case "reality":
+		tls := tlsFromQuery(q, security)
+		if tls.PublicKey == "" {
+			return nil, fmt.Errorf("reality requires pbk")
+		}
+		outbound["tls"] = tls.build()
+	default:
+		tls := tlsFromQuery(q, security)
+		if tls.ServerName == "" {
+			tls.ServerName = u.Hostname()
+		}
+		outbound["tls"] = tls.build()
+	}
+
+	if err := applyTransport(outbound, transportFromQuery(q)); err != nil {
+		return nil, err
+	}
+
+	return outbound, nil
+}
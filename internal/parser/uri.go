@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// uriParser 单个协议的分享链接解析函数
+type uriParser func(link string) (map[string]interface{}, error)
+
+// uriParsers 协议 scheme -> 解析函数
+var uriParsers = map[string]uriParser{
+	"vmess":     parseVmess,
+	"vless":     parseVless,
+	"trojan":    parseTrojan,
+	"ss":        parseShadowsocks,
+	"hysteria2": parseHysteria2,
+	"hy2":       parseHysteria2,
+	"tuic":      parseTuic,
+}
+
+// ParseURI 将单条分享链接转换为 sing-box outbound
+func ParseURI(link string) (map[string]interface{}, error) {
+	idx := strings.Index(link, "://")
+	if idx <= 0 {
+		return nil, fmt.Errorf("invalid share link")
+	}
+
+	scheme := strings.ToLower(link[:idx])
+	parse, ok := uriParsers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme: %s", scheme)
+	}
+
+	return parse(link)
+}
+
+// decodeBase64 兼容标准 / URL 安全、带或不带填充的 base64 解码
+func decodeBase64(s string) (string, error) {
+	s = strings.Join(strings.Fields(s), "")
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	}
+
+	var lastErr error
+	for _, enc := range encodings {
+		data, err := enc.DecodeString(s)
+		if err == nil {
+			return string(data), nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// parseStandardURI 解析 user@host:port?query#name 形式的链接，返回 URL 与端口
+func parseStandardURI(link string) (*url.URL, int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse uri error: %w", err)
+	}
+
+	if u.Hostname() == "" {
+		return nil, 0, fmt.Errorf("missing server address")
+	}
+
+	port, err := parsePort(u.Port())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return u, port, nil
+}
+
+// parsePort 解析端口号
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid port: %q", s)
+	}
+	return port, nil
+}
+
+// nodeTag 生成节点 tag，链接未提供名称时使用 server:port
+func nodeTag(name, server string, port int) string {
+	name = strings.TrimSpace(name)
+	if name != "" {
+		return name
+	}
+	return net.JoinHostPort(server, strconv.Itoa(port))
+}
+
+// parseBool 解析链接中的布尔参数
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// splitList 按逗号拆分列表参数
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// tlsOptions 构建 sing-box 的 tls 配置
+type tlsOptions struct {
+	ServerName  string
+	Insecure    bool
+	ALPN        []string
+	Fingerprint string
+	PublicKey   string // reality
+	ShortID     string // reality
+	Reality     bool
+}
+
+// build 转换为 sing-box outbound.tls
+func (o tlsOptions) build() map[string]interface{} {
+	tls := map[string]interface{}{
+		"enabled": true,
+	}
+	if o.ServerName != "" {
+		tls["server_name"] = o.ServerName
+	}
+	if o.Insecure {
+		tls["insecure"] = true
+	}
+	if len(o.ALPN) > 0 {
+		tls["alpn"] = o.ALPN
+	}
+	if o.Fingerprint != "" {
+		tls["utls"] = map[string]interface{}{
+			"enabled":     true,
+			"fingerprint": o.Fingerprint,
+		}
+	}
+	if o.Reality {
+		reality := map[string]interface{}{
+			"enabled":    true,
+			"public_key": o.PublicKey,
+		}
+		if o.ShortID != "" {
+			reality["short_id"] = o.ShortID
+		}
+		tls["reality"] = reality
+		// reality 需要 uTLS
+		if o.Fingerprint == "" {
+			tls["utls"] = map[string]interface{}{
+				"enabled":     true,
+				"fingerprint": "chrome",
+			}
+		}
+	}
+	return tls
+}
+
+// tlsFromQuery 从 vless / trojan 风格的查询参数构建 tls 配置
+func tlsFromQuery(q url.Values, security string) tlsOptions {
+	opts := tlsOptions{
+		ServerName:  firstNonEmpty(q.Get("sni"), q.Get("peer")),
+		Insecure:    parseBool(q.Get("allowInsecure")) || parseBool(q.Get("insecure")) || parseBool(q.Get("allow_insecure")),
+		ALPN:        splitList(q.Get("alpn")),
+		Fingerprint: q.Get("fp"),
+	}
+	if security == "reality" {
+		opts.Reality = true
+		opts.PublicKey = q.Get("pbk")
+		opts.ShortID = q.Get("sid")
+	}
+	return opts
+}
+
+// transportOptions 传输层参数
+type transportOptions struct {
+	Type        string
+	Host        string
+	Path        string
+	ServiceName string
+	HeaderType  string
+}
+
+// build 转换为 sing-box outbound.transport，tcp 等无需 transport 时返回 nil
+func (o transportOptions) build() (map[string]interface{}, error) {
+	switch strings.ToLower(o.Type) {
+	case "", "tcp", "raw":
+		// tcp + http 伪装对应 sing-box 的 http 传输
+		if o.HeaderType != "http" {
+			return nil, nil
+		}
+		transport := map[string]interface{}{"type": "http"}
+		if o.Host != "" {
+			transport["host"] = splitList(o.Host)
+		}
+		if o.Path != "" {
+			transport["path"] = o.Path
+		}
+		return transport, nil
+
+	case "ws", "websocket":
+		transport := map[string]interface{}{"type": "ws"}
+		path := o.Path
+		// 处理 path 中的 ?ed=2048 早期数据参数
+		if idx := strings.Index(path, "?ed="); idx >= 0 {
+			if ed, err := strconv.Atoi(path[idx+4:]); err == nil {
+				transport["max_early_data"] = ed
+				transport["early_data_header_name"] = "Sec-WebSocket-Protocol"
+				path = path[:idx]
+			}
+		}
+		if path != "" {
+			transport["path"] = path
+		}
+		if o.Host != "" {
+			transport["headers"] = map[string]interface{}{"Host": o.Host}
+		}
+		return transport, nil
+
+	case "grpc":
+		transport := map[string]interface{}{"type": "grpc"}
+		if o.ServiceName != "" {
+			transport["service_name"] = o.ServiceName
+		}
+		return transport, nil
+
+	case "http", "h2":
+		transport := map[string]interface{}{"type": "http"}
+		if o.Host != "" {
+			transport["host"] = splitList(o.Host)
+		}
+		if o.Path != "" {
+			transport["path"] = o.Path
+		}
+		return transport, nil
+
+	case "httpupgrade":
+		transport := map[string]interface{}{"type": "httpupgrade"}
+		if o.Host != "" {
+			transport["host"] = o.Host
+		}
+		if o.Path != "" {
+			transport["path"] = o.Path
+		}
+		return transport, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", o.Type)
+	}
+}
+
+// transportFromQuery 从 vless / trojan 风格的查询参数构建传输层配置
+func transportFromQuery(q url.Values) transportOptions {
+	return transportOptions{
+		Type:        q.Get("type"),
+		Host:        q.Get("host"),
+		Path:        q.Get("path"),
+		ServiceName: firstNonEmpty(q.Get("serviceName"), q.Get("service_name")),
+		HeaderType:  q.Get("headerType"),
+	}
+}
+
+// applyTransport 将传输层配置写入 outbound
+func applyTransport(outbound map[string]interface{}, opts transportOptions) error {
+	transport, err := opts.build()
+	if err != nil {
+		return err
+	}
+	if transport != nil {
+		outbound["transport"] = transport
+	}
+	return nil
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
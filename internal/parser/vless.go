@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// parseVless 解析 vless://uuid@server:port?params#name 链接
+func parseVless(link string) (map[string]interface{}, error) {
+	u, port, err := parseStandardURI(link)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid := u.User.Username()
+	if uuid == "" {
+		return nil, fmt.Errorf("missing uuid")
+	}
+
+	q := u.Query()
+	outbound := map[string]interface{}{
+		"type":        "vless",
+		"tag":         nodeTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"uuid":        uuid,
+	}
+
+	if flow := q.Get("flow"); flow != "" {
+		outbound["flow"] = flow
+	}
+	if q.Get("packetEncoding") != "" {
+		outbound["packet_encoding"] = q.Get("packetEncoding")
+	}
+
+	switch security := q.Get("security"); security {
+	case "tls", "xtls", "reality":
+		tls := tlsFromQuery(q, security)
+		if security == "reality" && tls.PublicKey == "" {
+			return nil, fmt.Errorf("reality requires pbk")
+		}
+		outbound["tls"] = tls.build()
+	case "", "none":
+	default:
+		return nil, fmt.Errorf("unsupported security: %s", security)
+	}
+
+	if err := applyTransport(outbound, transportFromQuery(q)); err != nil {
+		return nil, err
+	}
+
+	return outbound, nil
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vmessLink v2rayN 格式的 vmess 链接内容
+type vmessLink struct {
+	PS            string      `json:"ps"`
+	Add           string      `json:"add"`
+	Port          interface{} `json:"port"`
+	ID            string      `json:"id"`
+	Aid           interface{} `json:"aid"`
+	Scy           string      `json:"scy"`
+	Net           string      `json:"net"`
+	Type          string      `json:"type"`
+	Host          string      `json:"host"`
+	Path          string      `json:"path"`
+	TLS           string      `json:"tls"`
+	SNI           string      `json:"sni"`
+	ALPN          string      `json:"alpn"`
+	FP            string      `json:"fp"`
+	AllowInsecure interface{} `json:"allowInsecure"`
+}
+
+// parseVmess 解析 vmess://base64(json) 链接
+func parseVmess(link string) (map[string]interface{}, error) {
+	payload := strings.TrimPrefix(link[strings.Index(link, "://")+3:], "/")
+	if idx := strings.Index(payload, "#"); idx >= 0 {
+		payload = payload[:idx]
+	}
+
+	decoded, err := decodeBase64(payload)
+	if err != nil {
+		return nil, fmt.Errorf("decode vmess payload error: %w", err)
+	}
+
+	var v vmessLink
+	if err := json.Unmarshal([]byte(decoded), &v); err != nil {
+		return nil, fmt.Errorf("parse vmess json error: %w", err)
+	}
+
+	if v.Add == "" || v.ID == "" {
+		return nil, fmt.Errorf("missing server or uuid")
+	}
+
+	port, err := parsePort(anyToString(v.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	security := v.Scy
+	if security == "" {
+		security = "auto"
+	}
+
+	outbound := map[string]interface{}{
+		"type":        "vmess",
+		"tag":         nodeTag(v.PS, v.Add, port),
+		"server":      v.Add,
+		"server_port": port,
+		"uuid":        v.ID,
+		"security":    security,
+		"alter_id":    anyToInt(v.Aid),
+	}
+
+	if v.TLS == "tls" {
+		outbound["tls"] = tlsOptions{
+			ServerName:  firstNonEmpty(v.SNI, v.Host),
+			Insecure:    parseBool(anyToString(v.AllowInsecure)),
+			ALPN:        splitList(v.ALPN),
+			Fingerprint: v.FP,
+		}.build()
+	}
+
+	transport := transportOptions{
+		Type:       v.Net,
+		Host:       v.Host,
+		Path:       v.Path,
+		HeaderType: v.Type,
+	}
+	if strings.ToLower(v.Net) == "grpc" {
+		transport.ServiceName = v.Path
+	}
+	if err := applyTransport(outbound, transport); err != nil {
+		return nil, err
+	}
+
+	return outbound, nil
+}
+
+// anyToString 将 JSON 中可能为数字或字符串的字段统一转为字符串
+func anyToString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// anyToInt 将 JSON 中可能为数字或字符串的字段统一转为整数
+func anyToInt(v interface{}) int {
+	i, _ := strconv.Atoi(anyToString(v))
+	return i
+}
@@ -0,0 +1,174 @@
+// Package region 根据节点名称识别节点所在地区
+package region
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// entry 地区及其识别关键词
+type entry struct {
+	code     string   // ISO 3166-1 alpha-2
+	keywords []string // 中文名称、城市与英文名称，英文不区分大小写
+}
+
+// regions 识别表，按顺序匹配，城市名放在所属地区中；印度尼西亚需排在印度之前
+var regions = []entry{
+	{"HK", []string{"香港", "港区", "Hong Kong", "HongKong", "홍콩", "Гонконг"}},
+	{"TW", []string{"台湾", "臺灣", "台北", "台中", "新北", "Taiwan", "Taipei", "대만", "Тайвань"}},
+	{"MO", []string{"澳门", "澳門", "Macau", "Macao"}},
+	{"JP", []string{"日本", "东京", "東京", "大阪", "埼玉", "Japan", "Tokyo", "Osaka", "일본", "Япония"}},
+	{"KR", []string{"韩国", "韓國", "首尔", "首爾", "春川", "Korea", "Seoul", "한국", "서울", "韓国", "ソウル", "Корея"}},
+	{"SG", []string{"新加坡", "狮城", "獅城", "Singapore", "싱가포르", "シンガポール", "Сингапур"}},
+	{"US", []string{"美国", "美國", "洛杉矶", "圣何塞", "硅谷", "西雅图", "纽约", "芝加哥", "达拉斯", "凤凰城", "United States", "America", "Los Angeles", "San Jose", "Seattle", "New York", "Chicago", "Dallas", "미국", "アメリカ", "米国", "США"}},
+	{"CA", []string{"加拿大", "多伦多", "温哥华", "蒙特利尔", "Canada", "Toronto", "Vancouver", "Montreal"}},
+	{"GB", []string{"英国", "英國", "伦敦", "London", "United Kingdom", "Britain", "England", "イギリス", "영국"}},
+	{"DE", []string{"德国", "德國", "法兰克福", "Germany", "Frankfurt", "Deutschland", "ドイツ", "독일", "Германия"}},
+	{"FR", []string{"法国", "法國", "巴黎", "France", "Paris", "フランス", "프랑스"}},
+	{"NL", []string{"荷兰", "荷蘭", "阿姆斯特丹", "Netherlands", "Amsterdam"}},
+	{"RU", []string{"俄罗斯", "俄羅斯", "莫斯科", "Russia", "Moscow", "ロシア", "러시아", "Россия", "Москва"}},
+	{"TR", []string{"土耳其", "伊斯坦布尔", "Turkey", "Türkiye", "Istanbul"}},
+	{"ID", []string{"印尼", "印度尼西亚", "雅加达", "Indonesia", "Jakarta"}},
+	{"IN", []string{"印度", "孟买", "India", "Mumbai"}},
+	{"AU", []string{"澳大利亚", "澳洲", "悉尼", "墨尔本", "Australia", "Sydney", "Melbourne"}},
+	{"MY", []string{"马来西亚", "馬來西亞", "吉隆坡", "Malaysia", "Kuala Lumpur"}},
+	{"TH", []string{"泰国", "泰國", "曼谷", "Thailand", "Bangkok"}},
+	{"VN", []string{"越南", "胡志明", "河内", "Vietnam", "Hanoi"}},
+	{"PH", []string{"菲律宾", "菲律賓", "马尼拉", "Philippines", "Manila"}},
+	{"AR", []string{"阿根廷", "Argentina"}},
+	{"BR", []string{"巴西", "圣保罗", "Brazil", "Sao Paulo"}},
+}
+
+// codePattern 匹配名称中独立出现的大写地区代码，如 "HK-01"、"JP02"、"[US]"；前面紧跟数字时不匹配，避免将 "100GB" 识别为英国
+var codePattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9])(HK|TW|MO|JP|KR|SG|US|CA|UK|GB|DE|FR|NL|RU|TR|AU|MY|TH|VN|PH|AR|BR)(?:[^A-Za-z]|$)`)
+
+// Detect 识别节点名称中的地区，返回 ISO 3166-1 alpha-2 代码，无法识别时返回空字符串
+// 依次匹配旗帜 emoji、各语言的地区与城市名称、独立出现的地区代码
+func Detect(name string) string {
+	if code := flagCode(name); code != "" {
+		return code
+	}
+
+	lower := strings.ToLower(name)
+	for _, r := range regions {
+		for _, keyword := range r.keywords {
+			if strings.Contains(lower, strings.ToLower(keyword)) {
+				return r.code
+			}
+		}
+	}
+
+	if m := codePattern.FindStringSubmatch(name); m != nil {
+		if m[1] == "UK" {
+			return "GB"
+		}
+		return m[1]
+	}
+	return ""
+}
+
+// regionalIndicatorA 区域指示符号 🇦，两个区域指示符号组成一个旗帜 emoji
+const regionalIndicatorA = 0x1F1E6
+
+// flagCode 返回名称中第一个旗帜 emoji 对应的地区代码
+func flagCode(name string) string {
+	runes := []rune(name)
+	for i := 0; i+1 < len(runes); i++ {
+		a, b := runes[i]-regionalIndicatorA, runes[i+1]-regionalIndicatorA
+		if a >= 0 && a < 26 && b >= 0 && b < 26 {
+			return string([]rune{'A' + a, 'A' + b})
+		}
+	}
+	return ""
+}
+
+// HasFlag 名称中是否已包含旗帜 emoji
+func HasFlag(name string) bool {
+	return flagCode(name) != ""
+}
+
+// Flag 将地区代码转换为旗帜 emoji，代码无效时返回空字符串
+func Flag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	code = strings.ToUpper(code)
+	var b strings.Builder
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		b.WriteRune(regionalIndicatorA + c - 'A')
+	}
+	return b.String()
+}
+
+var (
+	dbMutex sync.RWMutex
+	db      *maxminddb.Reader
+)
+
+// OpenDatabase 打开 GeoLite2 / GeoIP2 Country 或 City 数据库，path 为空时关闭已打开的数据库
+func OpenDatabase(path string) error {
+	var reader *maxminddb.Reader
+	if path != "" {
+		var err error
+		reader, err = maxminddb.Open(path)
+		if err != nil {
+			return fmt.Errorf("open geoip database error: %w", err)
+		}
+	}
+
+	dbMutex.Lock()
+	old := db
+	db = reader
+	dbMutex.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// LookupIP 按服务器 IP 查询地区，未打开数据库、server 不是 IP 或查询不到时返回空字符串
+func LookupIP(server string) string {
+	ip := net.ParseIP(strings.Trim(server, "[]"))
+	if ip == nil {
+		return ""
+	}
+
+	dbMutex.RLock()
+	defer dbMutex.RUnlock()
+	if db == nil {
+		return ""
+	}
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		RegisteredCountry struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"registered_country"`
+	}
+	if err := db.Lookup(ip, &record); err != nil {
+		return ""
+	}
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode
+	}
+	return record.RegisteredCountry.ISOCode
+}
+
+// Classify 识别节点地区：先按名称识别，无法识别时按服务器 IP 查询 GeoIP 数据库
+func Classify(name, server string) string {
+	if code := Detect(name); code != "" {
+		return code
+	}
+	return LookupIP(server)
+}
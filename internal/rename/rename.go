@@ -0,0 +1,191 @@
+// Package rename 按配置的规则重写节点名称（outbound tag）
+package rename
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+	"github.com/haierkeys/singbox-subscribe-convert/internal/region"
+	"github.com/haierkeys/singbox-subscribe-convert/pkg/util"
+)
+
+// Node 待重命名的节点
+type Node struct {
+	Source   string                 // 所属订阅源
+	Outbound map[string]interface{} // sing-box outbound，重命名后直接修改其 tag
+}
+
+// rule 编译后的重命名规则
+type rule struct {
+	global.RenameRule
+	pattern *regexp.Regexp
+}
+
+// Pipeline 编译后的重命名规则列表
+type Pipeline struct {
+	rules []rule
+}
+
+// Compile 编译重命名规则
+func Compile(rules []global.RenameRule) (*Pipeline, error) {
+	p := &Pipeline{rules: make([]rule, 0, len(rules))}
+	for i, r := range rules {
+		compiled := rule{RenameRule: r}
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rename[%d] invalid pattern: %w", i, err)
+			}
+			compiled.pattern = re
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+// Apply 依次执行所有规则并改写节点 tag，同一订阅源中引用旧 tag 的 detour 一并更新
+// 规则结果为空时保留原名称；返回名称发生变化的节点数
+func (p *Pipeline) Apply(nodes []Node) int {
+	if len(p.rules) == 0 {
+		return 0
+	}
+
+	original := make([]string, len(nodes))
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		original[i], _ = node.Outbound["tag"].(string)
+		names[i] = original[i]
+	}
+
+	for _, r := range p.rules {
+		r.apply(nodes, names)
+	}
+
+	// 记录各订阅源的 tag 变化，用于改写 detour
+	renamed := make(map[string]map[string]string)
+	count := 0
+	for i, node := range nodes {
+		if names[i] == "" || names[i] == original[i] {
+			continue
+		}
+		if renamed[node.Source] == nil {
+			renamed[node.Source] = make(map[string]string)
+		}
+		renamed[node.Source][original[i]] = names[i]
+		node.Outbound["tag"] = names[i]
+		count++
+	}
+
+	for _, node := range nodes {
+		detour, ok := node.Outbound["detour"].(string)
+		if !ok {
+			continue
+		}
+		if name, ok := renamed[node.Source][detour]; ok {
+			node.Outbound["detour"] = name
+		}
+	}
+	return count
+}
+
+// matches 判断规则是否作用于该节点
+func (r rule) matches(node Node, name string) bool {
+	if len(r.Sources) > 0 && !util.InSlice(r.Sources, node.Source) {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(name)
+}
+
+// apply 对所有节点执行一条规则
+func (r rule) apply(nodes []Node, names []string) {
+	if r.Type == global.RenameTemplate {
+		r.applyTemplate(nodes, names)
+		return
+	}
+
+	for i, node := range nodes {
+		if !r.matches(node, names[i]) {
+			continue
+		}
+		switch r.Type {
+		case global.RenameReplace:
+			names[i] = r.pattern.ReplaceAllString(names[i], r.Replace)
+		case global.RenamePrefix:
+			names[i] = r.Value + names[i]
+		case global.RenameSuffix:
+			names[i] = names[i] + r.Value
+		case global.RenameFlag:
+			if !region.HasFlag(names[i]) {
+				if flag := region.Flag(classify(node, names[i])); flag != "" {
+					names[i] = flag + " " + names[i]
+				}
+			}
+		case global.RenameTrim:
+			name := strings.Join(strings.Fields(names[i]), " ")
+			if r.Value != "" {
+				name = strings.TrimSpace(strings.Trim(name, r.Value))
+			}
+			names[i] = name
+		}
+	}
+}
+
+// classify 按当前名称与服务器 IP 识别节点地区
+func classify(node Node, name string) string {
+	server, _ := node.Outbound["server"].(string)
+	return region.Classify(name, server)
+}
+
+// templateVar 名称模板中的变量，如 {region}、{index:2}
+var templateVar = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+
+// indexMarker 计算序号分组时代替 {index} 的占位符
+const indexMarker = "\x00"
+
+// applyTemplate 按模板生成名称，{index} 为除序号外其余部分相同的节点按顺序编号（从 1 开始）
+func (r rule) applyTemplate(nodes []Node, names []string) {
+	counters := make(map[string]int)
+	for i, node := range nodes {
+		if !r.matches(node, names[i]) {
+			continue
+		}
+
+		outboundType, _ := node.Outbound["type"].(string)
+		code := classify(node, names[i])
+		vars := map[string]string{
+			"name":   names[i],
+			"source": node.Source,
+			"type":   outboundType,
+			"region": code,
+			"flag":   region.Flag(code),
+		}
+
+		// 先以占位符代替序号得到分组键，再替换为该分组内的序号
+		key := expand(r.Value, vars, func(int) string { return indexMarker })
+		counters[key]++
+		index := counters[key]
+
+		name := expand(r.Value, vars, func(width int) string {
+			return fmt.Sprintf("%0*d", width, index)
+		})
+		names[i] = strings.Join(strings.Fields(name), " ")
+	}
+}
+
+// expand 替换名称模板中的变量，未知变量原样保留
+func expand(format string, vars map[string]string, index func(width int) string) string {
+	return templateVar.ReplaceAllStringFunc(format, func(m string) string {
+		sub := templateVar.FindStringSubmatch(m)
+		if sub[1] == "index" {
+			width, _ := strconv.Atoi(sub[2])
+			return index(width)
+		}
+		if val, ok := vars[sub[1]]; ok {
+			return val
+		}
+		return m
+	})
+}
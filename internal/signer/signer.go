@@ -0,0 +1,131 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalid 签名无效或内容被篡改
+	ErrInvalid = errors.New("invalid signature")
+	// ErrExpired 签名已过期
+	ErrExpired = errors.New("signature expired")
+)
+
+// Claims 签名链接绑定的内容
+type Claims struct {
+	Template  string `json:"t"`
+	Filter    string `json:"f,omitempty"`
+	ExpiresAt int64  `json:"e"` // Unix 时间戳（秒）
+}
+
+// Expiry 过期时间
+func (c Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Sign 使用 HMAC-SHA256 对 claims 签名，返回 "payload.signature" 格式的 token
+func Sign(key string, claims Claims) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("signing key is empty")
+	}
+	if claims.Template == "" {
+		return "", fmt.Errorf("template is required")
+	}
+	if claims.ExpiresAt <= 0 {
+		return "", fmt.Errorf("expiry is required")
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(key, encoded)), nil
+}
+
+// Verify 校验 token 的签名与有效期，返回绑定的 claims
+func Verify(key, token string, now time.Time) (Claims, error) {
+	if key == "" {
+		return Claims{}, ErrInvalid
+	}
+
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalid
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(key, encoded)) {
+		return Claims{}, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Template == "" {
+		return Claims{}, ErrInvalid
+	}
+	if !now.Before(claims.Expiry()) {
+		return claims, ErrExpired
+	}
+	return claims, nil
+}
+
+// BuildURL 生成签名订阅链接，baseURL 为服务的访问地址（如 https://sub.example.com/），extra 为附加的查询参数
+func BuildURL(baseURL, key string, claims Claims, extra url.Values) (string, error) {
+	token, err := Sign(key, claims)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid base url: %s", baseURL)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	query := url.Values{}
+	for k, v := range extra {
+		query[k] = v
+	}
+	query.Set("template", claims.Template)
+	query.Set("sign", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// mac 计算 HMAC-SHA256
+func mac(key, data string) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// ParseExpiry 解析有效期：RFC3339 时间、Go duration（如 72h）或天数（如 7d）
+func ParseExpiry(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n), nil
+		}
+		return time.Time{}, fmt.Errorf("invalid expiry: %s", s)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid expiry: %s", s)
+	}
+	return now.Add(d), nil
+}
@@ -0,0 +1,128 @@
+package signer
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	claims := Claims{Template: "default", Filter: "香港|日本", ExpiresAt: now.Add(time.Hour).Unix()}
+	token, err := Sign("key", claims)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+	other, err := Sign("key", Claims{Template: "admin", ExpiresAt: claims.ExpiresAt})
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name  string
+		key   string
+		token string
+		now   time.Time
+		err   error
+	}{
+		{"valid", "key", token, now, nil},
+		{"one second before expiry", "key", token, claims.Expiry().Add(-time.Second), nil},
+		{"expired at expiry", "key", token, claims.Expiry(), ErrExpired},
+		{"expired after expiry", "key", token, now.Add(24 * time.Hour), ErrExpired},
+		{"wrong key", "other", token, now, ErrInvalid},
+		{"empty key", "", token, now, ErrInvalid},
+		{"tampered payload", "key", otherPayload + "." + sig, now, ErrInvalid},
+		{"truncated signature", "key", payload + "." + sig[:len(sig)-2], now, ErrInvalid},
+		{"missing signature", "key", payload, now, ErrInvalid},
+		{"not base64", "key", payload + ".***", now, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.key, tt.token, tt.now)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.err)
+			}
+			// 过期时仍返回 claims，便于记录日志
+			if (err == nil || errors.Is(err, ErrExpired)) && got != claims {
+				t.Errorf("claims = %+v, want %+v", got, claims)
+			}
+		})
+	}
+}
+
+func TestSignRequiresFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		claims Claims
+	}{
+		{"empty key", "", Claims{Template: "default", ExpiresAt: 1}},
+		{"missing template", "key", Claims{ExpiresAt: 1}},
+		{"missing expiry", "key", Claims{Template: "default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Sign(tt.key, tt.claims); err == nil {
+				t.Error("Sign succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestBuildURL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	claims := Claims{Template: "ios", Filter: "HK", ExpiresAt: now.Add(time.Hour).Unix()}
+	link, err := BuildURL("https://sub.example.com", "key", claims, url.Values{"format": {"clash"}})
+	if err != nil {
+		t.Fatalf("BuildURL error: %v", err)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse %s error: %v", link, err)
+	}
+	query := u.Query()
+	if u.Path != "/" || query.Get("template") != "ios" || query.Get("format") != "clash" {
+		t.Errorf("link = %s", link)
+	}
+	got, err := Verify("key", query.Get("sign"), now)
+	if err != nil || got != claims {
+		t.Errorf("Verify(link sign) = %+v, %v, want %+v", got, err, claims)
+	}
+
+	if _, err := BuildURL("sub.example.com", "key", claims, nil); err == nil {
+		t.Error("BuildURL with relative base url succeeded, want error")
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2026-02-01T00:00:00Z", want: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "72h", want: now.Add(72 * time.Hour)},
+		{in: "7d", want: now.AddDate(0, 0, 7)},
+		{in: "0d", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "xd", wantErr: true},
+		{in: "tomorrow", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseExpiry(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExpiry(%q) error = %v, wantErr %t", tt.in, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseExpiry(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
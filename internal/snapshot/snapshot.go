@@ -0,0 +1,219 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/haierkeys/singbox-subscribe-convert/global"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// 快照目标类型
+const (
+	KindNode     = "node"
+	KindTemplate = "template"
+	KindClash    = "clash"
+)
+
+// Current 表示当前缓存文件，可在 diff 中代替快照 ID
+const Current = "current"
+
+// idLayout 快照 ID 的时间格式，同时作为快照文件名
+const idLayout = "20060102-150405.000000"
+
+var idPattern = regexp.MustCompile(`^\d{8}-\d{6}\.\d{6}$`)
+
+var cfg *global.Config
+
+// Init 初始化快照管理
+func Init(c *global.Config) {
+	cfg = c
+}
+
+// Target 可保存快照的缓存文件
+type Target struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Path string `json:"-"`
+}
+
+// Snapshot 单个快照信息
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// Resolve 根据类型和名称查找快照目标
+func Resolve(kind, name string) (Target, error) {
+	switch kind {
+	case KindNode:
+		if _, ok := cfg.GetSubscription(name); !ok {
+			return Target{}, fmt.Errorf("subscription '%s' not found", name)
+		}
+		return Target{Kind: kind, Name: name, Path: cfg.GetSubscriptionFilePathByName(name)}, nil
+	case KindTemplate:
+		if _, ok := cfg.GetTemplate(name); !ok {
+			return Target{}, fmt.Errorf("template '%s' not found", name)
+		}
+		return Target{Kind: kind, Name: name, Path: cfg.GetTemplateFilePathByName(name)}, nil
+	case KindClash:
+		if tpl, ok := cfg.GetTemplate(name); !ok || tpl.ClashURL == "" {
+			return Target{}, fmt.Errorf("clash template '%s' not found", name)
+		}
+		return Target{Kind: kind, Name: name, Path: cfg.GetClashTemplateFilePathByName(name)}, nil
+	default:
+		return Target{}, fmt.Errorf("invalid kind: %s", kind)
+	}
+}
+
+// Targets 返回所有启用的订阅源与模板对应的快照目标
+func Targets() []Target {
+	var targets []Target
+	for _, sub := range cfg.GetEnabledSubscriptions() {
+		targets = append(targets, Target{Kind: KindNode, Name: sub.Name, Path: cfg.GetSubscriptionFilePathByName(sub.Name)})
+	}
+
+	names := make([]string, 0)
+	enabledTemplates := cfg.GetEnabledTemplates()
+	for name := range enabledTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		targets = append(targets, Target{Kind: KindTemplate, Name: name, Path: cfg.GetTemplateFilePathByName(name)})
+		if enabledTemplates[name].ClashURL != "" {
+			targets = append(targets, Target{Kind: KindClash, Name: name, Path: cfg.GetClashTemplateFilePathByName(name)})
+		}
+	}
+	return targets
+}
+
+// dir 缓存文件对应的快照目录
+func dir(cachePath string) string {
+	return filepath.Join(cfg.GetSnapshotDirectory(), filepath.Base(cachePath))
+}
+
+// Save 为缓存文件保存一个新快照，并清理超出保留数量的旧快照
+func Save(cachePath string, data []byte) error {
+	limit := cfg.GetSnapshotLimit()
+	if limit == 0 {
+		return nil
+	}
+
+	snapshotDir := dir(cachePath)
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return fmt.Errorf("create snapshot dir error: %w", err)
+	}
+
+	id := time.Now().UTC().Format(idLayout)
+	if err := os.WriteFile(filepath.Join(snapshotDir, id), data, 0644); err != nil {
+		return fmt.Errorf("write snapshot error: %w", err)
+	}
+
+	snapshots, err := List(cachePath)
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots[min(limit, len(snapshots)):] {
+		if err := os.Remove(filepath.Join(snapshotDir, s.ID)); err != nil {
+			return fmt.Errorf("remove snapshot error: %w", err)
+		}
+	}
+	return nil
+}
+
+// List 列出缓存文件的所有快照，最新的在前
+func List(cachePath string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir(cachePath))
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot dir error: %w", err)
+	}
+
+	snapshots := make([]Snapshot, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !idPattern.MatchString(entry.Name()) {
+			continue
+		}
+		t, err := time.Parse(idLayout, entry.Name())
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{ID: entry.Name(), Time: t, Size: info.Size()})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// Read 读取快照内容，id 为 Current 时读取当前缓存文件
+func Read(cachePath, id string) ([]byte, error) {
+	if id == Current {
+		data, err := os.ReadFile(cachePath)
+		if err != nil {
+			return nil, fmt.Errorf("read cache file error: %w", err)
+		}
+		return data, nil
+	}
+
+	if !idPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid snapshot id: %s", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir(cachePath), id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot '%s' not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot error: %w", err)
+	}
+	return data, nil
+}
+
+// Diff 输出两个快照之间的 unified diff，JSON 内容会先格式化以便逐行比较
+func Diff(cachePath, fromID, toID string) (string, error) {
+	from, err := Read(cachePath, fromID)
+	if err != nil {
+		return "", err
+	}
+	to, err := Read(cachePath, toID)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(prettyJSON(from))),
+		B:        difflib.SplitLines(string(prettyJSON(to))),
+		FromFile: fromID,
+		ToFile:   toID,
+		Context:  3,
+	})
+}
+
+// prettyJSON 合法 JSON 内容按缩进格式化，其他内容原样返回
+func prettyJSON(data []byte) []byte {
+	if !json.Valid(data) {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(data), "", "  "); err != nil {
+		return data
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
@@ -0,0 +1,124 @@
+package validator
+
+// topLevelFields sing-box 配置的顶层字段
+var topLevelFields = fieldSet(
+	"log", "dns", "ntp", "certificate", "endpoints", "inbounds", "outbounds", "route", "experimental", "services",
+)
+
+// dialFields 所有出站通用的拨号字段
+var dialFields = []string{
+	"detour", "bind_interface", "inet4_bind_address", "inet6_bind_address", "bind_address_no_port",
+	"routing_mark", "reuse_addr", "netns", "connect_timeout", "tcp_fast_open", "tcp_multi_path",
+	"disable_tcp_keep_alive", "tcp_keep_alive", "tcp_keep_alive_interval", "udp_fragment",
+	"domain_strategy", "domain_resolver", "fallback_delay",
+	"network_strategy", "network_type", "fallback_network_type",
+}
+
+// serverFields 连接远程服务器的出站通用字段
+var serverFields = []string{"server", "server_port"}
+
+// outboundFields 各出站类型允许的顶层字段（不含 type / tag）
+var outboundFields = map[string]map[string]bool{
+	"direct":   withDial("override_address", "override_port", "proxy_protocol"),
+	"block":    fieldSet(),
+	"dns":      fieldSet(),
+	"selector": fieldSet("outbounds", "default", "interrupt_exist_connections"),
+	"urltest":  fieldSet("outbounds", "url", "interval", "tolerance", "idle_timeout", "interrupt_exist_connections"),
+	"socks": withServer(
+		"version", "username", "password", "network", "udp_over_tcp",
+	),
+	"http": withServer(
+		"username", "password", "path", "headers", "tls",
+	),
+	"shadowsocks": withServer(
+		"method", "password", "plugin", "plugin_opts", "network", "udp_over_tcp", "multiplex",
+	),
+	"vmess": withServer(
+		"uuid", "security", "alter_id", "global_padding", "authenticated_length", "network",
+		"tls", "packet_encoding", "transport", "multiplex",
+	),
+	"vless": withServer(
+		"uuid", "flow", "network", "tls", "packet_encoding", "transport", "multiplex",
+	),
+	"trojan": withServer(
+		"password", "network", "tls", "transport", "multiplex",
+	),
+	"hysteria": withServer(
+		"server_ports", "hop_interval", "up", "up_mbps", "down", "down_mbps", "obfs", "auth", "auth_str",
+		"recv_window_conn", "recv_window", "disable_mtu_discovery", "network", "tls",
+	),
+	"hysteria2": withServer(
+		"server_ports", "hop_interval", "up_mbps", "down_mbps", "obfs", "password", "network", "tls", "brutal_debug",
+	),
+	"tuic": withServer(
+		"uuid", "password", "congestion_control", "udp_relay_mode", "udp_over_stream", "zero_rtt_handshake",
+		"heartbeat", "network", "tls",
+	),
+	"wireguard": withServer(
+		"system_interface", "gso", "interface_name", "local_address", "private_key", "peers",
+		"peer_public_key", "pre_shared_key", "reserved", "workers", "mtu", "network",
+	),
+	"shadowtls": withServer(
+		"version", "password", "tls",
+	),
+	"ssh": withServer(
+		"user", "password", "private_key", "private_key_path", "private_key_passphrase",
+		"host_key", "host_key_algorithms", "client_version",
+	),
+	"tor": withDial(
+		"executable_path", "extra_args", "data_directory", "torrc",
+	),
+	"anytls": withServer(
+		"password", "idle_session_check_interval", "idle_session_timeout", "min_idle_session", "tls",
+	),
+}
+
+// groupTypes 引用其他出站的策略组类型
+var groupTypes = fieldSet("selector", "urltest")
+
+// version sing-box 版本号（major.minor）
+type version struct {
+	major, minor int
+}
+
+// before 是否早于 other
+func (v version) before(other version) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	return v.minor < other.minor
+}
+
+// outboundSince 出站类型的最低支持版本
+var outboundSince = map[string]version{
+	"anytls": {1, 12},
+}
+
+// outboundRemoved 出站类型被移除的版本
+var outboundRemoved = map[string]version{
+	"block":     {1, 13},
+	"dns":       {1, 13},
+	"wireguard": {1, 13},
+}
+
+// endpointsSince 顶层 endpoints 字段的最低支持版本
+var endpointsSince = version{1, 11}
+
+// fieldSet 构建字段集合
+func fieldSet(fields ...string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		set[f] = true
+	}
+	return set
+}
+
+// withDial 构建包含通用拨号字段的字段集合
+func withDial(fields ...string) map[string]bool {
+	return fieldSet(append(fields, dialFields...)...)
+}
+
+// withServer 构建包含服务器与拨号字段的字段集合
+func withServer(fields ...string) map[string]bool {
+	return withDial(append(fields, serverFields...)...)
+}
@@ -0,0 +1,243 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Issue 单条校验问题
+type Issue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Error 渲染结果校验失败，包含全部问题
+type Error struct {
+	Issues []Issue `json:"issues"`
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		msgs = append(msgs, issue.Path+": "+issue.Message)
+	}
+	return "invalid sing-box config: " + strings.Join(msgs, "; ")
+}
+
+// checker 收集校验问题
+type checker struct {
+	issues   []Issue
+	warnings []Issue
+	strict   bool // 未知字段按错误处理
+	version  *version
+}
+
+func (c *checker) add(path, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// unknown 记录未知字段，非严格模式下只作为警告，避免字段列表滞后于 sing-box 时拒绝可用的配置
+func (c *checker) unknown(path, format string, args ...interface{}) {
+	if c.strict {
+		c.add(path, format, args...)
+		return
+	}
+	c.warnings = append(c.warnings, Issue{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate 校验渲染后的 sing-box 配置，返回未知字段等警告与校验错误
+// targetVersion 为模板目标 sing-box 版本（如 1.12），为空时跳过版本相关检查；strict 为 true 时未知字段按错误处理
+// 检查内容：JSON 合法性、未知字段、出站类型、重复 tag、策略组与路由引用的 tag 是否存在
+func Validate(data []byte, targetVersion string, strict bool) ([]Issue, error) {
+	c := &checker{strict: strict}
+	if targetVersion != "" {
+		v, err := parseVersion(targetVersion)
+		if err != nil {
+			return nil, err
+		}
+		c.version = &v
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		c.add("$", "invalid json: %v", err)
+		return nil, &Error{Issues: c.issues}
+	}
+
+	for _, key := range sortedKeys(doc) {
+		if !topLevelFields[key] {
+			c.unknown(key, "unknown field")
+		}
+	}
+	if _, ok := doc["endpoints"]; ok && c.version != nil && c.version.before(endpointsSince) {
+		c.add("endpoints", "requires sing-box %d.%d or later", endpointsSince.major, endpointsSince.minor)
+	}
+
+	tags := make(map[string]bool)
+	c.collectTags(doc, "endpoints", tags, nil)
+	var groups []map[string]interface{}
+	var groupPaths []string
+	c.collectTags(doc, "outbounds", tags, func(path string, item map[string]interface{}) {
+		c.checkOutbound(path, item)
+		if groupTypes[str(item["type"])] {
+			groups = append(groups, item)
+			groupPaths = append(groupPaths, path)
+		}
+	})
+
+	for i, group := range groups {
+		path := groupPaths[i]
+		refs, ok := group["outbounds"].([]interface{})
+		if !ok && group["outbounds"] != nil {
+			c.add(path+".outbounds", "must be an array")
+		}
+		for j, ref := range refs {
+			c.checkRef(fmt.Sprintf("%s.outbounds[%d]", path, j), ref, tags)
+		}
+		if def, ok := group["default"]; ok {
+			c.checkRef(path+".default", def, tags)
+		}
+	}
+
+	if route, ok := doc["route"].(map[string]interface{}); ok {
+		if final, ok := route["final"]; ok {
+			c.checkRef("route.final", final, tags)
+		}
+		if rules, ok := route["rules"].([]interface{}); ok {
+			c.checkRules("route.rules", rules, tags)
+		}
+	}
+
+	if len(c.issues) > 0 {
+		return c.warnings, &Error{Issues: c.issues}
+	}
+	return c.warnings, nil
+}
+
+// collectTags 遍历 outbounds / endpoints 列表，记录 tag 并检查重复
+func (c *checker) collectTags(doc map[string]interface{}, key string, tags map[string]bool, visit func(path string, item map[string]interface{})) {
+	raw, exists := doc[key]
+	if !exists {
+		return
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		c.add(key, "must be an array")
+		return
+	}
+
+	for i, entry := range list {
+		path := fmt.Sprintf("%s[%d]", key, i)
+		item, ok := entry.(map[string]interface{})
+		if !ok {
+			c.add(path, "must be an object")
+			continue
+		}
+
+		tag := str(item["tag"])
+		if tag == "" {
+			c.add(path+".tag", "missing tag")
+		} else if tags[tag] {
+			c.add(path+".tag", "duplicate tag %q", tag)
+		} else {
+			tags[tag] = true
+		}
+
+		if visit != nil {
+			visit(path, item)
+		}
+	}
+}
+
+// checkOutbound 检查出站类型、版本与未知字段
+func (c *checker) checkOutbound(path string, item map[string]interface{}) {
+	outboundType := str(item["type"])
+	fields, ok := outboundFields[outboundType]
+	if !ok {
+		c.add(path+".type", "unknown outbound type %q", outboundType)
+		return
+	}
+
+	if c.version != nil {
+		if since, ok := outboundSince[outboundType]; ok && c.version.before(since) {
+			c.add(path+".type", "%s requires sing-box %d.%d or later", outboundType, since.major, since.minor)
+		}
+		if removed, ok := outboundRemoved[outboundType]; ok && !c.version.before(removed) {
+			c.add(path+".type", "%s was removed in sing-box %d.%d", outboundType, removed.major, removed.minor)
+		}
+	}
+
+	for _, key := range sortedKeys(item) {
+		if key == "type" || key == "tag" {
+			continue
+		}
+		if !fields[key] {
+			c.unknown(path+"."+key, "unknown field for %s outbound", outboundType)
+		}
+	}
+}
+
+// checkRules 检查路由规则（含逻辑规则）引用的出站
+func (c *checker) checkRules(path string, rules []interface{}, tags map[string]bool) {
+	for i, entry := range rules {
+		rule, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rulePath := fmt.Sprintf("%s[%d]", path, i)
+		if outbound, ok := rule["outbound"]; ok {
+			c.checkRef(rulePath+".outbound", outbound, tags)
+		}
+		if nested, ok := rule["rules"].([]interface{}); ok {
+			c.checkRules(rulePath+".rules", nested, tags)
+		}
+	}
+}
+
+// checkRef 检查引用的 tag 是否存在
+func (c *checker) checkRef(path string, ref interface{}, tags map[string]bool) {
+	tag, ok := ref.(string)
+	if !ok {
+		c.add(path, "must be a string")
+		return
+	}
+	if !tags[tag] {
+		c.add(path, "references missing tag %q", tag)
+	}
+}
+
+// parseVersion 解析 major.minor[.patch] 格式的版本号
+func parseVersion(s string) (version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return version{}, fmt.Errorf("invalid version: %s", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return version{}, fmt.Errorf("invalid version: %s", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return version{}, fmt.Errorf("invalid version: %s", s)
+	}
+	return version{major: major, minor: minor}, nil
+}
+
+// str 读取字符串值
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// sortedKeys 按字母序返回 map 的键，保证问题输出顺序稳定
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// validConfig 通过校验的最小配置，各用例在此基础上修改
+const validConfig = `{
+	"outbounds": [
+		{"type": "selector", "tag": "proxy", "outbounds": ["hk", "direct"], "default": "hk"},
+		{"type": "shadowsocks", "tag": "hk", "server": "hk.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "pw"},
+		{"type": "direct", "tag": "direct"}
+	],
+	"route": {"final": "proxy", "rules": [{"domain": ["example.com"], "outbound": "direct"}]}
+}`
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		version  string
+		strict   bool
+		issues   []Issue // 校验错误，为空表示校验通过
+		warnings []Issue
+	}{
+		{
+			name: "valid",
+			data: validConfig,
+		},
+		{
+			name:   "invalid json",
+			data:   `{"outbounds": [`,
+			issues: []Issue{{Path: "$", Message: "invalid json: unexpected end of JSON input"}},
+		},
+		{
+			name: "duplicate tag",
+			data: `{"outbounds": [
+				{"type": "direct", "tag": "direct"},
+				{"type": "block", "tag": "direct"}
+			]}`,
+			issues: []Issue{{Path: "outbounds[1].tag", Message: `duplicate tag "direct"`}},
+		},
+		{
+			name: "duplicate tag across endpoints and outbounds",
+			data: `{
+				"endpoints": [{"type": "wireguard", "tag": "wg"}],
+				"outbounds": [{"type": "direct", "tag": "wg"}]
+			}`,
+			issues: []Issue{{Path: "outbounds[0].tag", Message: `duplicate tag "wg"`}},
+		},
+		{
+			name:   "missing tag",
+			data:   `{"outbounds": [{"type": "direct"}]}`,
+			issues: []Issue{{Path: "outbounds[0].tag", Message: "missing tag"}},
+		},
+		{
+			name: "selector references missing tag",
+			data: `{"outbounds": [
+				{"type": "selector", "tag": "proxy", "outbounds": ["hk", "jp"], "default": "us"},
+				{"type": "direct", "tag": "hk"}
+			]}`,
+			issues: []Issue{
+				{Path: "outbounds[0].outbounds[1]", Message: `references missing tag "jp"`},
+				{Path: "outbounds[0].default", Message: `references missing tag "us"`},
+			},
+		},
+		{
+			name:   "urltest outbounds must be an array",
+			data:   `{"outbounds": [{"type": "urltest", "tag": "auto", "outbounds": "hk"}]}`,
+			issues: []Issue{{Path: "outbounds[0].outbounds", Message: "must be an array"}},
+		},
+		{
+			name: "route references missing tag",
+			data: `{
+				"outbounds": [{"type": "direct", "tag": "direct"}],
+				"route": {"final": "proxy", "rules": [{"type": "logical", "rules": [{"outbound": "block"}]}]}
+			}`,
+			issues: []Issue{
+				{Path: "route.final", Message: `references missing tag "proxy"`},
+				{Path: "route.rules[0].rules[0].outbound", Message: `references missing tag "block"`},
+			},
+		},
+		{
+			name:   "unknown outbound type",
+			data:   `{"outbounds": [{"type": "ssr", "tag": "hk"}]}`,
+			issues: []Issue{{Path: "outbounds[0].type", Message: `unknown outbound type "ssr"`}},
+		},
+		{
+			name: "unknown fields warn in non-strict mode",
+			data: `{
+				"outbounds": [{"type": "direct", "tag": "direct", "server": "x"}],
+				"extra": {}
+			}`,
+			warnings: []Issue{
+				{Path: "extra", Message: "unknown field"},
+				{Path: "outbounds[0].server", Message: "unknown field for direct outbound"},
+			},
+		},
+		{
+			name: "unknown fields fail in strict mode",
+			data: `{
+				"outbounds": [{"type": "direct", "tag": "direct", "server": "x"}],
+				"extra": {}
+			}`,
+			strict: true,
+			issues: []Issue{
+				{Path: "extra", Message: "unknown field"},
+				{Path: "outbounds[0].server", Message: "unknown field for direct outbound"},
+			},
+		},
+		{
+			name:    "endpoints require 1.11",
+			data:    `{"endpoints": [{"type": "wireguard", "tag": "wg"}]}`,
+			version: "1.10",
+			issues:  []Issue{{Path: "endpoints", Message: "requires sing-box 1.11 or later"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := Validate([]byte(tt.data), tt.version, tt.strict)
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings = %v, want %v", warnings, tt.warnings)
+			}
+
+			if len(tt.issues) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *Error
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("error = %v, want *Error", err)
+			}
+			if !reflect.DeepEqual(validationErr.Issues, tt.issues) {
+				t.Errorf("issues = %v, want %v", validationErr.Issues, tt.issues)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidVersion(t *testing.T) {
+	var validationErr *Error
+	if _, err := Validate([]byte(validConfig), "latest", false); err == nil || errors.As(err, &validationErr) {
+		t.Errorf("error = %v, want version parse error", err)
+	}
+}
@@ -37,16 +37,25 @@ func Start(ctx context.Context, cfg *global.Config, logger *zap.Logger, onNodeCh
 	debounce := make(map[string]time.Time)
 	debounceInterval := 1 * time.Second
 
-	// 获取节点文件路径
-	nodeFilePath, _ := filepath.Abs(cfg.GetNodeFilePath())
+	// 构建订阅源节点文件路径映射
+	nodeFilePaths := make(map[string]string) // absPath -> subscriptionName
+
+	for _, sub := range cfg.GetEnabledSubscriptions() {
+		absPath, _ := filepath.Abs(cfg.GetSubscriptionFilePathByName(sub.Name))
+		nodeFilePaths[absPath] = sub.Name
+	}
 
 	// 构建模板文件路径映射
 	templateFilePaths := make(map[string]string) // absPath -> templateName
 
 	// 为每个启用的模板创建路径映射
-	for name := range cfg.GetEnabledTemplates() {
+	for name, tpl := range cfg.GetEnabledTemplates() {
 		absPath, _ := filepath.Abs(cfg.GetTemplateFilePathByName(name))
 		templateFilePaths[absPath] = name
+		if tpl.ClashURL != "" {
+			clashPath, _ := filepath.Abs(cfg.GetClashTemplateFilePathByName(name))
+			templateFilePaths[clashPath] = name
+		}
 	}
 
 	for {
@@ -60,7 +69,8 @@ func Start(ctx context.Context, cfg *global.Config, logger *zap.Logger, onNodeCh
 				return
 			}
 
-			if event.Op&fsnotify.Write == fsnotify.Write {
+			// 缓存文件通过临时文件 + 重命名原子写入，重命名产生的是 Create 事件
+			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
 				absPath, _ := filepath.Abs(event.Name)
 
 				if lastTime, exists := debounce[absPath]; exists {
@@ -70,9 +80,10 @@ func Start(ctx context.Context, cfg *global.Config, logger *zap.Logger, onNodeCh
 				}
 				debounce[absPath] = time.Now()
 
-				if absPath == nodeFilePath {
+				if subName, isNode := nodeFilePaths[absPath]; isNode {
 					logger.Info("Node file changed, reloading...",
 						zap.String("file", absPath),
+						zap.String("subscription", subName),
 					)
 					if err := onNodeChange(); err != nil {
 						logger.Error("Error reloading node data",
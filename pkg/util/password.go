@@ -1,6 +1,9 @@
 package util
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,3 +23,28 @@ func CheckPasswordHash(hash, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// IsPasswordHash 判断字符串是否为bcrypt哈希值
+// s: 待判断的字符串
+// 返回值: 是bcrypt哈希值返回true，否则返回false
+func IsPasswordHash(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
+
+// VerifyPassword 校验密码，兼容明文与bcrypt哈希两种存储方式
+// stored: 配置中存储的密码（明文或bcrypt哈希值）
+// password: 待验证的密码
+// 返回值: 如果密码匹配返回true，否则返回false
+func VerifyPassword(stored, password string) bool {
+	if stored == "" || password == "" {
+		return false
+	}
+	if IsPasswordHash(stored) {
+		return CheckPasswordHash(stored, password)
+	}
+	// 明文比较前先取摘要，避免通过比较耗时泄露长度或内容
+	a := sha256.Sum256([]byte(stored))
+	b := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}